@@ -0,0 +1,501 @@
+package logstore
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/gouniverse/sb"
+)
+
+// DefaultArchiveFilePrefix is the file name prefix of the archive files
+const DefaultArchiveFilePrefix = "logs"
+
+// ArchiveRotation defines when the archive writer starts a new file
+type ArchiveRotation int
+
+const (
+	// ArchiveRotateDaily starts a new file for each day of the log times (UTC)
+	ArchiveRotateDaily ArchiveRotation = iota
+
+	// ArchiveRotateSize starts a new file once MaxFileSize is reached
+	ArchiveRotateSize
+
+	// ArchiveRotateDailyAndSize starts a new file for each day and
+	// once MaxFileSize is reached within a day
+	ArchiveRotateDailyAndSize
+)
+
+// ArchiveWriterOptions define the options of an archive writer
+type ArchiveWriterOptions struct {
+	// Directory the archive files are written to, created if missing
+	Directory string
+
+	// FilePrefix is the file name prefix, defaults to DefaultArchiveFilePrefix.
+	// Files are named <prefix>-<yyyy-mm-dd>-<sequence>.jsonl.gz
+	FilePrefix string
+
+	// Rotation defines when a new file is started, defaults to ArchiveRotateDaily
+	Rotation ArchiveRotation
+
+	// MaxFileSize is the uncompressed size in bytes after which a new file
+	// is started when rotating by size
+	MaxFileSize int64
+}
+
+// ArchiveWriter writes logs to gzip compressed JSON Lines files,
+// one log per line, rotated by day or size
+type ArchiveWriter struct {
+	options ArchiveWriterOptions
+
+	file        *os.File
+	gzipWriter  *gzip.Writer
+	fileDay     string
+	fileSize    int64
+	fileCount   int64
+	fileEntries map[string]int64
+
+	files []string
+	count int64
+
+	// fileComplete is called with the IDs of the logs of each completed
+	// file, set by Archive to delete them, the IDs are kept only if set
+	fileComplete func(name string, ids []string) error
+	fileIDs      []string
+}
+
+// ArchiveResult reports an archive job
+type ArchiveResult struct {
+	// Archived is the number of logs written to the archive
+	Archived int64
+
+	// Deleted is the number of archived logs deleted from the store
+	Deleted int64
+
+	// Files are the archive files written
+	Files []string
+}
+
+// NewArchiveWriter creates an archive writer, the files are created on the first write
+func NewArchiveWriter(options ArchiveWriterOptions) (*ArchiveWriter, error) {
+	if options.Directory == "" {
+		return nil, errors.New("log store: archive directory is required")
+	}
+
+	if options.FilePrefix == "" {
+		options.FilePrefix = DefaultArchiveFilePrefix
+	}
+
+	if options.Rotation != ArchiveRotateDaily && options.MaxFileSize <= 0 {
+		return nil, errors.New("log store: archive max file size is required when rotating by size")
+	}
+
+	if err := os.MkdirAll(options.Directory, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &ArchiveWriter{
+		options:     options,
+		fileEntries: map[string]int64{},
+		files:       []string{},
+	}, nil
+}
+
+// Write appends the log to the current archive file, rotating first if due
+func (w *ArchiveWriter) Write(logEntry Log) error {
+	line, err := json.Marshal(logEntry)
+
+	if err != nil {
+		return err
+	}
+
+	line = append(line, '\n')
+
+	day := ""
+	if logEntry.Time != nil {
+		day = logEntry.Time.UTC().Format("2006-01-02")
+	}
+
+	if w.shouldRotate(day, int64(len(line))) {
+		if err := w.rotate(day); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.gzipWriter.Write(line); err != nil {
+		return err
+	}
+
+	w.fileSize += int64(len(line))
+	w.fileEntries[w.file.Name()]++
+	w.count++
+
+	if w.fileComplete != nil {
+		w.fileIDs = append(w.fileIDs, logEntry.ID)
+	}
+
+	return nil
+}
+
+// Count returns the number of logs written
+func (w *ArchiveWriter) Count() int64 {
+	return w.count
+}
+
+// Files returns the archive files written, in order
+func (w *ArchiveWriter) Files() []string {
+	return append([]string{}, w.files...)
+}
+
+// Close completes the current archive file
+func (w *ArchiveWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+
+	name := w.file.Name()
+	err := w.gzipWriter.Close()
+
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	ids := w.fileIDs
+
+	w.file = nil
+	w.gzipWriter = nil
+	w.fileIDs = nil
+
+	if err != nil || w.fileComplete == nil {
+		return err
+	}
+
+	return w.fileComplete(name, ids)
+}
+
+// Verify reads the archive files back and checks they hold the logs written
+func (w *ArchiveWriter) Verify() error {
+	if w.file != nil {
+		return errors.New("log store: archive writer must be closed before verifying")
+	}
+
+	total := int64(0)
+
+	for _, name := range w.files {
+		count, err := countArchiveLines(name)
+
+		if err != nil {
+			return err
+		}
+
+		if count != w.fileEntries[name] {
+			return fmt.Errorf("log store: archive %s holds %d logs, %d were written", name, count, w.fileEntries[name])
+		}
+
+		total += count
+	}
+
+	if total != w.count {
+		return fmt.Errorf("log store: archive holds %d logs, %d were written", total, w.count)
+	}
+
+	return nil
+}
+
+// shouldRotate returns whether a new file is due before writing the line of the day
+func (w *ArchiveWriter) shouldRotate(day string, lineSize int64) bool {
+	if w.file == nil {
+		return true
+	}
+
+	rotateDaily := w.options.Rotation == ArchiveRotateDaily || w.options.Rotation == ArchiveRotateDailyAndSize
+	rotateSize := w.options.Rotation == ArchiveRotateSize || w.options.Rotation == ArchiveRotateDailyAndSize
+
+	if rotateDaily && day != w.fileDay {
+		return true
+	}
+
+	// a file holds at least one log, however large
+	return rotateSize && w.fileSize > 0 && w.fileSize+lineSize > w.options.MaxFileSize
+}
+
+// rotate completes the current file and creates the next one, never
+// overwriting the files of earlier archive jobs
+func (w *ArchiveWriter) rotate(day string) error {
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if day == "" {
+		day = "undated"
+	}
+
+	if day != w.fileDay {
+		w.fileCount = 0
+	}
+
+	for {
+		w.fileCount++
+
+		name := filepath.Join(w.options.Directory, w.options.FilePrefix+"-"+day+"-"+fmt.Sprintf("%03d", w.fileCount)+".jsonl.gz")
+
+		file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		w.file = file
+		w.gzipWriter = gzip.NewWriter(file)
+		w.fileDay = day
+		w.fileSize = 0
+		w.files = append(w.files, name)
+
+		return nil
+	}
+}
+
+// countArchiveLines returns the number of lines of the gzip compressed file
+func countArchiveLines(name string) (int64, error) {
+	file, err := os.Open(name)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer gzipReader.Close()
+
+	reader := bufio.NewReader(gzipReader)
+	count := int64(0)
+
+	for {
+		_, err := reader.ReadBytes('\n')
+
+		if err == io.EOF {
+			return count, nil
+		}
+
+		if err != nil {
+			return count, err
+		}
+
+		count++
+	}
+}
+
+// Archive writes the logs matching the query to the archive writer, oldest
+// first, and closes the writer. Each archive file is read back once
+// complete and only then its logs are deleted, so an interrupted archive
+// leaves the logs of the unfinished file in the store. The query cannot
+// have a limit, an offset or an order, all matching logs are archived.
+func (st *storeImplementation) Archive(ctx context.Context, query LogQueryInterface, writer *ArchiveWriter) (ArchiveResult, error) {
+	result := ArchiveResult{Files: []string{}}
+
+	if writer == nil {
+		return result, errors.New("log store: archive writer is required")
+	}
+
+	if query == nil {
+		query = LogQuery()
+	}
+
+	if err := st.validateLogQuery(ctx, query); err != nil {
+		return result, err
+	}
+
+	if query.HasLimit() || query.HasOffset() || query.HasOrderBy() || query.HasSortDirection() {
+		return result, errors.New("log store: archive query cannot have a limit, offset or order")
+	}
+
+	// a writer can be reused, a file left open by earlier writes is
+	// completed first and only the logs and files of this job are reported
+	if err := writer.Close(); err != nil {
+		return result, err
+	}
+
+	previousCount := writer.Count()
+	previousFiles := len(writer.files)
+
+	writer.fileComplete = func(name string, ids []string) error {
+		count, err := countArchiveLines(name)
+
+		if err != nil {
+			return err
+		}
+
+		if count != int64(len(ids)) {
+			return fmt.Errorf("log store: archive %s holds %d logs, %d were written", name, count, len(ids))
+		}
+
+		deleted, err := st.deleteByIDs(ctx, ids)
+		result.Deleted += deleted
+
+		return err
+	}
+
+	defer func() {
+		writer.fileComplete = nil
+	}()
+
+	read, err := st.archiveLogs(ctx, query, writer)
+
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+
+	result.Archived = writer.Count() - previousCount
+	result.Files = writer.Files()[previousFiles:]
+
+	if err != nil {
+		return result, err
+	}
+
+	if read != result.Archived {
+		return result, fmt.Errorf("log store: %d logs were read, %d archived", read, result.Archived)
+	}
+
+	return result, nil
+}
+
+// archiveLogs writes the matching logs to the writer, paging by time and ID,
+// and returns the number of logs written
+func (st *storeImplementation) archiveLogs(ctx context.Context, query LogQueryInterface, writer *ArchiveWriter) (int64, error) {
+	written := int64(0)
+	var position *logCursor
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+
+		q := st.logSelectQuery(query).
+			Select(logSelectColumns()...).
+			Order(goqu.C(COLUMN_TIME).Asc(), goqu.C(COLUMN_ID).Asc()).
+			Limit(uint(st.retentionChunkSize))
+
+		if position != nil {
+			q = q.Where(logCursorCondition(position, sb.ASC))
+		}
+
+		sqlStr, sqlParams, err := q.Prepared(true).ToSQL()
+
+		if err != nil {
+			return written, err
+		}
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		logs, err := st.queryLogs(ctx, sqlStr, sqlParams)
+
+		if err != nil {
+			return written, err
+		}
+
+		// a rotation while writing deletes the logs of the completed file
+		for _, logEntry := range logs {
+			if err := writer.Write(logEntry); err != nil {
+				return written, err
+			}
+
+			written++
+		}
+
+		if len(logs) < st.retentionChunkSize {
+			return written, nil
+		}
+
+		last := logs[len(logs)-1]
+
+		if last.Time == nil {
+			return written, errors.New("log store: cannot page past log " + last.ID + " without time")
+		}
+
+		position = &logCursor{Time: last.Time.UTC(), ID: last.ID}
+	}
+}
+
+// queryLogs runs the select statement and scans the logs
+func (st *storeImplementation) queryLogs(ctx context.Context, sqlStr string, sqlParams []any) ([]Log, error) {
+	rows, err := st.db.QueryContext(ctx, sqlStr, sqlParams...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	logs := []Log{}
+
+	for rows.Next() {
+		logEntry, err := scanLog(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		logs = append(logs, *logEntry)
+	}
+
+	return logs, rows.Err()
+}
+
+// deleteByIDs deletes the logs in chunks of the retention chunk size
+func (st *storeImplementation) deleteByIDs(ctx context.Context, ids []string) (int64, error) {
+	deleted := int64(0)
+
+	for start := 0; start < len(ids); start += st.retentionChunkSize {
+		end := min(start+st.retentionChunkSize, len(ids))
+
+		sqlStr, sqlParams, err := st.dialect().
+			Delete(st.logTableName).
+			Where(goqu.C(COLUMN_ID).In(ids[start:end])).
+			Prepared(true).
+			ToSQL()
+
+		if err != nil {
+			return deleted, err
+		}
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		result, err := st.db.ExecContext(ctx, sqlStr, sqlParams...)
+
+		if err != nil {
+			return deleted, err
+		}
+
+		count, err := result.RowsAffected()
+
+		if err != nil {
+			return deleted, err
+		}
+
+		deleted += count
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,67 @@
+package logstore
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gouniverse/uid"
+)
+
+func Test_ArchiveWriter_RotateSize(t *testing.T) {
+	directory := t.TempDir()
+
+	writer, err := NewArchiveWriter(ArchiveWriterOptions{
+		Directory:   directory,
+		FilePrefix:  "app",
+		Rotation:    ArchiveRotateSize,
+		MaxFileSize: 200,
+	})
+
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	logTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 4; i++ {
+		err := writer.Write(Log{ID: uid.MicroUid(), Level: LevelInfo, Message: strings.Repeat("x", 50), Time: &logTime})
+		if err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if err := writer.Verify(); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(writer.Files()) != 4 || writer.Count() != 4 {
+		t.Fatalf("Expected a file per log over the size limit, received %v", writer.Files())
+	}
+
+	// a second job does not overwrite the files of the first
+	second, err := NewArchiveWriter(ArchiveWriterOptions{
+		Directory:   directory,
+		FilePrefix:  "app",
+		Rotation:    ArchiveRotateSize,
+		MaxFileSize: 200,
+	})
+
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if err := second.Write(Log{ID: "second", Level: LevelInfo, Time: &logTime}); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	second.Close()
+
+	if !strings.HasSuffix(second.Files()[0], "app-2024-01-01-005.jsonl.gz") {
+		t.Fatalf("Expected the next free file name, received %v", second.Files())
+	}
+}
@@ -0,0 +1,194 @@
+package logstore
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Defaults for the asynchronous writer
+const (
+	DefaultAsyncQueueSize     = 1000
+	DefaultAsyncFlushInterval = time.Second
+	DefaultAsyncMaxBatchSize  = 100
+)
+
+// asyncWriter queues log entries and writes them from a background goroutine
+type asyncWriter struct {
+	queue         chan *Log
+	flushRequests chan chan error
+	stop          chan struct{}
+	done          chan struct{}
+
+	// closing unblocks the enqueues waiting for room in a full queue,
+	// so that close does not wait for them while taking the mutex
+	closing     chan struct{}
+	closingOnce sync.Once
+
+	// mutex guards closed, so no entry is enqueued after the queue is drained
+	mutex  sync.RWMutex
+	closed bool
+
+	flushInterval time.Duration
+	maxBatchSize  int
+
+	// write persists a batch of entries
+	write func(entries []*Log) error
+}
+
+// newAsyncWriter creates an asynchronous writer and starts its background goroutine
+func newAsyncWriter(queueSize int, flushInterval time.Duration, maxBatchSize int, write func(entries []*Log) error) *asyncWriter {
+	if queueSize <= 0 {
+		queueSize = DefaultAsyncQueueSize
+	}
+
+	if flushInterval <= 0 {
+		flushInterval = DefaultAsyncFlushInterval
+	}
+
+	if maxBatchSize <= 0 {
+		maxBatchSize = DefaultAsyncMaxBatchSize
+	}
+
+	writer := &asyncWriter{
+		queue:         make(chan *Log, queueSize),
+		flushRequests: make(chan chan error),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+		closing:       make(chan struct{}),
+		flushInterval: flushInterval,
+		maxBatchSize:  maxBatchSize,
+		write:         write,
+	}
+
+	go writer.run()
+
+	return writer
+}
+
+// enqueue adds an entry to the queue, blocking while the queue is full
+func (w *asyncWriter) enqueue(ctx context.Context, logEntry *Log) error {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	if w.closed {
+		return ErrStoreClosed
+	}
+
+	select {
+	case w.queue <- logEntry:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.closing:
+		return ErrStoreClosed
+	}
+}
+
+// flush waits until all entries queued so far are written
+func (w *asyncWriter) flush(ctx context.Context) error {
+	reply := make(chan error, 1)
+
+	select {
+	case w.flushRequests <- reply:
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close stops accepting entries and waits until the queue is drained
+func (w *asyncWriter) close(ctx context.Context) error {
+	w.closingOnce.Do(func() {
+		close(w.closing)
+	})
+
+	w.mutex.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.stop)
+	}
+	w.mutex.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run is the background loop, writing whenever a batch is full,
+// the flush interval elapses, a flush is requested or the writer is closed
+func (w *asyncWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Log, 0, w.maxBatchSize)
+
+	writeBatch := func() error {
+		if len(batch) < 1 {
+			return nil
+		}
+
+		err := w.write(batch)
+
+		if err != nil {
+			log.Println("log store: async write failed:", err)
+		}
+
+		batch = make([]*Log, 0, w.maxBatchSize)
+
+		return err
+	}
+
+	// drain writes everything currently queued, returning the first error
+	drain := func() error {
+		var firstErr error
+
+		for {
+			select {
+			case logEntry := <-w.queue:
+				batch = append(batch, logEntry)
+				if len(batch) >= w.maxBatchSize {
+					if err := writeBatch(); err != nil && firstErr == nil {
+						firstErr = err
+					}
+				}
+			default:
+				if err := writeBatch(); err != nil && firstErr == nil {
+					firstErr = err
+				}
+				return firstErr
+			}
+		}
+	}
+
+	for {
+		select {
+		case logEntry := <-w.queue:
+			batch = append(batch, logEntry)
+			if len(batch) >= w.maxBatchSize {
+				writeBatch()
+			}
+		case <-ticker.C:
+			writeBatch()
+		case reply := <-w.flushRequests:
+			reply <- drain()
+		case <-w.stop:
+			drain()
+			return
+		}
+	}
+}
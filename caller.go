@@ -0,0 +1,97 @@
+package logstore
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// logstorePackage is the function name prefix of the frames in this package
+const logstorePackage = "github.com/gouniverse/logstore."
+
+// sourceColumnLength is the length of the source file and function columns
+const sourceColumnLength = 255
+
+// captureCaller sets the source of the log to the first caller outside
+// of this package, skipping the configured number of additional frames
+func (st *storeImplementation) captureCaller(logEntry *Log) {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	skip := st.callerSkip
+
+	for {
+		frame, more := frames.Next()
+
+		if !isLogstoreFrame(frame) {
+			if skip <= 0 {
+				logEntry.SourceFile = frame.File
+				logEntry.SourceLine = frame.Line
+				logEntry.SourceFunction = frame.Function
+				return
+			}
+			skip--
+		}
+
+		if !more {
+			return
+		}
+	}
+}
+
+// callerStack returns the stack trace of the caller, one "function\n\tfile:line"
+// entry per frame, skipping the frames of this package (but not its tests)
+func callerStack() string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack strings.Builder
+
+	for {
+		frame, more := frames.Next()
+
+		if !isLogstoreFrame(frame) {
+			stack.WriteString(frame.Function)
+			stack.WriteString("\n\t")
+			stack.WriteString(frame.File)
+			stack.WriteString(":")
+			stack.WriteString(strconv.Itoa(frame.Line))
+			stack.WriteString("\n")
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return stack.String()
+}
+
+// isLogstoreFrame returns whether the frame belongs to this package,
+// frames of the package tests are not considered logstore frames
+func isLogstoreFrame(frame runtime.Frame) bool {
+	if !strings.HasPrefix(frame.Function, logstorePackage) {
+		return false
+	}
+
+	return !strings.HasSuffix(frame.File, "_test.go")
+}
+
+// truncateSource shortens the source file and function to the length of
+// their columns, keeping the end, as it names the file and the method
+func truncateSource(logEntry *Log) {
+	logEntry.SourceFile = truncateStart(logEntry.SourceFile, sourceColumnLength)
+	logEntry.SourceFunction = truncateStart(logEntry.SourceFunction, sourceColumnLength)
+}
+
+// truncateStart returns the last length characters of the value
+func truncateStart(value string, length int) string {
+	runes := []rune(value)
+
+	if len(runes) <= length {
+		return value
+	}
+
+	return string(runes[len(runes)-length:])
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gouniverse/logstore"
+	"github.com/gouniverse/sb"
+)
+
+// newFlagSet creates the flag set of the command, writing errors and usage to stderr
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("logstore "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	return flags
+}
+
+// runMigrate applies the pending migrations, or prints them with -dry-run
+func runMigrate(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("migrate", stderr)
+	conn := addConnectionFlags(flags)
+	dryRun := flags.Bool("dry-run", false, "print the SQL statements without executing them")
+	status := flags.Bool("status", false, "print the current and the latest schema version")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	store, closeStore, err := conn.open(logstore.NewStoreOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	defer closeStore()
+
+	if *status {
+		migrationStatus, err := store.MigrationStatus()
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "current version: %d\nlatest version: %d\n", migrationStatus.CurrentVersion, migrationStatus.LatestVersion)
+
+		for _, migration := range migrationStatus.Pending {
+			fmt.Fprintf(stdout, "pending: %d %s\n", migration.Version, migration.Description)
+		}
+
+		return nil
+	}
+
+	if *dryRun {
+		statements, err := store.MigrateDryRun()
+
+		for _, statement := range statements {
+			fmt.Fprintln(stdout, statement)
+		}
+
+		return err
+	}
+
+	return store.AutoMigrate()
+}
+
+// runQuery prints the logs matching the filters
+func runQuery(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("query", stderr)
+	conn := addConnectionFlags(flags)
+	filter := addFilterFlags(flags)
+	limit := flags.Int("limit", 100, "maximum number of logs, 0 for all")
+	offset := flags.Int("offset", 0, "number of logs skipped")
+	sortDirection := flags.String("sort", sb.DESC, "time order, asc or desc")
+	format := flags.String("format", string(logstore.ExportFormatText), "output format, text, jsonl or csv")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	query, err := filter.query(time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	query.SetSortDirection(*sortDirection)
+
+	if *limit > 0 {
+		query.SetLimit(*limit)
+	}
+
+	if *offset > 0 {
+		query.SetOffset(*offset)
+	}
+
+	store, closeStore, err := conn.open(logstore.NewStoreOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	defer closeStore()
+
+	_, err = store.Export(ctx, query, stdout, logstore.ExportOptions{
+		Format: logstore.ExportFormat(*format),
+	})
+
+	return err
+}
+
+// runCount prints the number of logs matching the filters
+func runCount(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("count", stderr)
+	conn := addConnectionFlags(flags)
+	filter := addFilterFlags(flags)
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	query, err := filter.query(time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	store, closeStore, err := conn.open(logstore.NewStoreOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	defer closeStore()
+
+	count, err := store.LogCount(query)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, count)
+
+	return nil
+}
+
+// runTail prints the latest logs and, with -f, polls for new ones
+// until interrupted
+func runTail(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("tail", stderr)
+	conn := addConnectionFlags(flags)
+	filter := addFilterFlags(flags)
+	lines := flags.Int("n", 10, "number of latest logs printed")
+	follow := flags.Bool("f", false, "keep printing new logs as they arrive")
+	interval := flags.Duration("interval", time.Second, "how often new logs are polled with -f")
+	format := flags.String("format", string(logstore.ExportFormatText), "output format, text or jsonl")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if *format == string(logstore.ExportFormatCSV) {
+		return errors.New("tail does not support the csv format")
+	}
+
+	exportOptions := logstore.ExportOptions{Format: logstore.ExportFormat(*format)}
+
+	query, err := filter.query(time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	store, closeStore, err := conn.open(logstore.NewStoreOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	defer closeStore()
+
+	logs := []logstore.Log{}
+
+	// a limit of 0 is no limit, so nothing is read for -n 0
+	if *lines > 0 {
+		logs, err = store.LogList(query.SetSortDirection(sb.DESC).SetLimit(*lines))
+
+		if err != nil {
+			return err
+		}
+
+		slices.Reverse(logs)
+
+		if err := logstore.ExportLogs(stdout, logs, exportOptions); err != nil {
+			return err
+		}
+	}
+
+	if !*follow {
+		return nil
+	}
+
+	// following starts after the newest log, which -n 0 does not print
+	if *lines <= 0 {
+		logs, err = store.LogList(query.SetSortDirection(sb.DESC).SetLimit(1))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	position := newTailPosition(logs)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		// relative -since and -until are evaluated against the time of each poll
+		query, _ := filter.query(time.Now())
+		query.SetSortDirection(sb.ASC).SetLimit(logstore.LogCursorDefaultLimit)
+
+		if !position.time.IsZero() {
+			query.SetTimeGte(position.time)
+		}
+
+		logs, err := store.LogList(query)
+
+		if err != nil {
+			return err
+		}
+
+		if err := logstore.ExportLogs(stdout, position.advance(logs), exportOptions); err != nil {
+			return err
+		}
+	}
+}
+
+// tailPosition is the time of the last printed log and the IDs printed at
+// that time, as logs with the same time are read again by the next poll
+type tailPosition struct {
+	time time.Time
+	ids  map[string]bool
+}
+
+func newTailPosition(logs []logstore.Log) *tailPosition {
+	position := &tailPosition{ids: map[string]bool{}}
+	position.advance(logs)
+	return position
+}
+
+// advance returns the logs not printed yet and moves past them,
+// the logs are in ascending time order
+func (position *tailPosition) advance(logs []logstore.Log) []logstore.Log {
+	unseen := []logstore.Log{}
+
+	for _, logEntry := range logs {
+		if logEntry.Time == nil || position.ids[logEntry.ID] {
+			continue
+		}
+
+		if !logEntry.Time.Equal(position.time) {
+			position.time = *logEntry.Time
+			position.ids = map[string]bool{}
+		}
+
+		position.ids[logEntry.ID] = true
+		unseen = append(unseen, logEntry)
+	}
+
+	return unseen
+}
+
+// runPurge deletes the logs expired by the retention flags
+func runPurge(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("purge", stderr)
+	conn := addConnectionFlags(flags)
+	maxAge := flags.String("max-age", "", "delete logs older than the age, i.e. 30d")
+	maxRows := flags.Int64("max-rows", 0, "keep only the most recent number of logs")
+	levelMaxAge := flags.String("level-max-age", "", "per level ages overriding -max-age, i.e. debug=3d,error=90d")
+	chunkSize := flags.Int("chunk-size", logstore.DefaultRetentionChunkSize, "number of logs deleted per statement")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	policy := logstore.RetentionPolicy{
+		MaxRows:     *maxRows,
+		LevelMaxAge: map[logstore.Level]time.Duration{},
+	}
+
+	if *maxAge != "" {
+		age, err := parseAge(*maxAge)
+
+		if err != nil {
+			return errors.New("-max-age: " + err.Error())
+		}
+
+		policy.MaxAge = age
+	}
+
+	for _, item := range splitList(*levelMaxAge) {
+		level, value, ok := strings.Cut(item, "=")
+
+		if !ok {
+			return errors.New("-level-max-age must be level=age pairs, received " + item)
+		}
+
+		age, err := parseAge(value)
+
+		if err != nil {
+			return errors.New("-level-max-age: " + err.Error())
+		}
+
+		severity, err := logstore.ParseLevel(level)
+
+		if err != nil {
+			return errors.New("-level-max-age: " + err.Error())
+		}
+
+		if _, exists := policy.LevelMaxAge[severity]; exists {
+			return errors.New("-level-max-age: level " + severity.String() + " is given more than once")
+		}
+
+		policy.LevelMaxAge[severity] = age
+	}
+
+	if policy.IsEmpty() {
+		return errors.New("-max-age, -max-rows or -level-max-age is required")
+	}
+
+	store, closeStore, err := conn.open(logstore.NewStoreOptions{
+		Retention:          policy,
+		RetentionChunkSize: *chunkSize,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	defer closeStore()
+
+	deleted, err := store.PurgeExpired(ctx)
+
+	fmt.Fprintf(stdout, "deleted %d logs\n", deleted)
+
+	return err
+}
+
+// runExport writes the logs matching the filters to a file or stdout
+func runExport(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("export", stderr)
+	conn := addConnectionFlags(flags)
+	filter := addFilterFlags(flags)
+	format := flags.String("format", string(logstore.ExportFormatCSV), "output format, csv, jsonl, ndjson or text")
+	output := flags.String("output", "-", "output file, - for stdout")
+	columns := flags.String("columns", "", "comma separated log columns of the csv format, i.e. time,level,message")
+	contextColumns := flags.String("context-columns", "", "comma separated context paths exported as csv columns, i.e. user_id,request.id")
+	sortDirection := flags.String("sort", sb.ASC, "time order, asc or desc")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	query, err := filter.query(time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	query.SetSortDirection(*sortDirection)
+
+	store, closeStore, err := conn.open(logstore.NewStoreOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	defer closeStore()
+
+	writer := stdout
+	var file *os.File
+
+	if *output != "-" {
+		file, err = os.Create(*output)
+
+		if err != nil {
+			return err
+		}
+
+		writer = file
+	}
+
+	options := logstore.ExportOptions{
+		Format:         logstore.ExportFormat(*format),
+		ContextColumns: splitList(*contextColumns),
+	}
+
+	if *columns != "" {
+		options.Columns = splitList(*columns)
+	}
+
+	count, err := store.Export(ctx, query, writer, options)
+
+	// the file is closed before reporting, as closing can fail to write it
+	if file != nil {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if *output != "-" {
+		fmt.Fprintf(stdout, "exported %d logs to %s\n", count, *output)
+	}
+
+	return nil
+}
+
+// runImport inserts the logs of a JSON Lines file or stdin
+func runImport(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("import", stderr)
+	conn := addConnectionFlags(flags)
+	input := flags.String("file", "-", "JSON Lines file, plain or gzip compressed, - for stdin")
+	batchSize := flags.Int("batch-size", logstore.DefaultImportBatchSize, "number of logs inserted at once")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	store, closeStore, err := conn.open(logstore.NewStoreOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	defer closeStore()
+
+	var reader io.Reader = os.Stdin
+
+	if *input != "-" {
+		file, err := os.Open(*input)
+
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		reader = file
+	}
+
+	progress, err := store.Import(ctx, reader, logstore.ImportOptions{
+		BatchSize: *batchSize,
+		Progress: func(progress logstore.ImportProgress) {
+			fmt.Fprintf(stderr, "imported %d, skipped %d\n", progress.Imported, progress.Skipped)
+		},
+	})
+
+	fmt.Fprintf(stdout, "imported %d logs, skipped %d duplicates\n", progress.Imported, progress.Skipped)
+
+	return err
+}
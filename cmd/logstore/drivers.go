@@ -0,0 +1,9 @@
+package main
+
+// The database/sql drivers of the databases the store supports
+import (
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/microsoft/go-mssqldb"
+)
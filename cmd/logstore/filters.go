@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gouniverse/logstore"
+)
+
+// filters holds the flags filtering the logs
+type filters struct {
+	id        string
+	level     string
+	minLevel  string
+	since     string
+	until     string
+	text      string
+	jsonPath  string
+	service   string
+	requestID string
+	traceID   string
+	userID    string
+}
+
+// addFilterFlags adds the filter flags to the flag set
+func addFilterFlags(flags *flag.FlagSet) *filters {
+	f := &filters{}
+
+	flags.StringVar(&f.id, "id", "", "log ID")
+	flags.StringVar(&f.level, "level", "", "level, or comma separated levels, i.e. error,fatal")
+	flags.StringVar(&f.minLevel, "min-level", "", "minimum level, i.e. warning for warnings and above")
+	flags.StringVar(&f.since, "since", "", "logs at or after, a RFC 3339 time or an age such as 90m, 12h or 7d")
+	flags.StringVar(&f.until, "until", "", "logs at or before, a RFC 3339 time or an age such as 90m, 12h or 7d")
+	flags.StringVar(&f.text, "text", "", "text the message contains")
+	flags.StringVar(&f.jsonPath, "json", "", "context value at a dotted path, i.e. request.id=req-1")
+	flags.StringVar(&f.service, "service", "", "service name")
+	flags.StringVar(&f.requestID, "request-id", "", "request ID")
+	flags.StringVar(&f.traceID, "trace-id", "", "trace ID")
+	flags.StringVar(&f.userID, "user-id", "", "user ID")
+
+	return f
+}
+
+// query returns a new log query with the filters applied
+func (f *filters) query(now time.Time) (logstore.LogQueryInterface, error) {
+	query := logstore.LogQuery()
+
+	if f.id != "" {
+		query.SetID(f.id)
+	}
+
+	// the levels are matched by severity, whatever name they were logged under
+	if levels := splitList(f.level); len(levels) > 0 {
+		severities := make([]logstore.Level, 0, len(levels))
+
+		for _, level := range levels {
+			severity, err := logstore.ParseLevel(level)
+
+			if err != nil {
+				return nil, err
+			}
+
+			severities = append(severities, severity)
+		}
+
+		query.SetSeverityIn(severities)
+	}
+
+	if f.minLevel != "" {
+		severity, err := logstore.ParseLevel(f.minLevel)
+
+		if err != nil {
+			return nil, err
+		}
+
+		query.SetSeverityGte(severity)
+	}
+
+	if f.since != "" {
+		since, err := parseTimeOrAge(f.since, now)
+
+		if err != nil {
+			return nil, errors.New("-since: " + err.Error())
+		}
+
+		query.SetTimeGte(since)
+	}
+
+	if f.until != "" {
+		until, err := parseTimeOrAge(f.until, now)
+
+		if err != nil {
+			return nil, errors.New("-until: " + err.Error())
+		}
+
+		query.SetTimeLte(until)
+	}
+
+	if f.text != "" {
+		query.SetMessageContains(f.text)
+	}
+
+	if f.jsonPath != "" {
+		path, value, ok := strings.Cut(f.jsonPath, "=")
+
+		if !ok {
+			return nil, errors.New("-json must be path=value")
+		}
+
+		query.SetContextPathEquals(path, value)
+	}
+
+	if f.service != "" {
+		query.SetService(f.service)
+	}
+
+	if f.requestID != "" {
+		query.SetRequestID(f.requestID)
+	}
+
+	if f.traceID != "" {
+		query.SetTraceID(f.traceID)
+	}
+
+	if f.userID != "" {
+		query.SetUserID(f.userID)
+	}
+
+	return query, query.Validate()
+}
+
+// parseTimeOrAge parses a RFC 3339 time, or an age before now
+func parseTimeOrAge(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+
+	age, err := parseAge(value)
+
+	if err != nil {
+		return time.Time{}, errors.New("expected a RFC 3339 time or an age, received " + value)
+	}
+
+	return now.Add(-age), nil
+}
+
+// parseAge parses a duration, additionally accepting days, i.e. 7d
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		count, err := strconv.Atoi(days)
+
+		if err != nil || count < 0 {
+			return 0, errors.New("invalid age " + value)
+		}
+
+		return time.Duration(count) * 24 * time.Hour, nil
+	}
+
+	age, err := time.ParseDuration(value)
+
+	if err != nil || age < 0 {
+		return 0, errors.New("invalid age " + value)
+	}
+
+	return age, nil
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(value string) []string {
+	items := []string{}
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
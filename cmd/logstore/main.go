@@ -0,0 +1,176 @@
+// Command logstore queries and maintains a log store from the command line.
+//
+// Usage:
+//
+//	logstore <command> -driver sqlite3 -dsn logs.db [flags]
+//
+// The commands are migrate, query, tail, count, purge, export and import,
+// run "logstore <command> -h" for the flags of a command. The driver and DSN
+// default to the LOGSTORE_DRIVER and LOGSTORE_DSN environment variables.
+//
+// The drivers of the databases the store supports are compiled in:
+// sqlite3, mysql, postgres and sqlserver.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/gouniverse/logstore"
+)
+
+// errUsage is returned for invalid arguments, the usage is printed already
+var errUsage = errors.New("invalid usage")
+
+// command is a subcommand of the tool
+type command struct {
+	name        string
+	description string
+	run         func(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error
+}
+
+func commands() []command {
+	return []command{
+		{"migrate", "apply the pending schema migrations", runMigrate},
+		{"query", "list the logs matching the filters", runQuery},
+		{"tail", "print the latest logs, with -f the new ones as they arrive", runTail},
+		{"count", "count the logs matching the filters", runCount},
+		{"purge", "delete the logs expired by a retention policy", runPurge},
+		{"export", "export the logs matching the filters as CSV, JSON Lines or text", runExport},
+		{"import", "import logs from JSON Lines written by export, archive or slog", runImport},
+	}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command of the arguments and returns the exit code
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) < 1 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(stderr)
+		return 2
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		err := cmd.run(ctx, args[1:], stdout, stderr)
+
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+
+		if err != nil {
+			fmt.Fprintln(stderr, "logstore "+cmd.name+": "+err.Error())
+			return 1
+		}
+
+		return 0
+	}
+
+	fmt.Fprintln(stderr, "logstore: unknown command "+args[0])
+	usage(stderr)
+
+	return 2
+}
+
+func usage(stderr io.Writer) {
+	fmt.Fprintln(stderr, "Usage: logstore <command> -driver <driver> -dsn <dsn> [flags]")
+	fmt.Fprintln(stderr)
+	fmt.Fprintln(stderr, "Commands:")
+
+	for _, cmd := range commands() {
+		fmt.Fprintf(stderr, "  %-8s %s\n", cmd.name, cmd.description)
+	}
+}
+
+// connection holds the flags selecting the database and the log table
+type connection struct {
+	driver string
+	dsn    string
+	table  string
+	debug  bool
+}
+
+// addConnectionFlags adds the database flags to the flag set
+func addConnectionFlags(flags *flag.FlagSet) *connection {
+	conn := &connection{}
+
+	flags.StringVar(&conn.driver, "driver", os.Getenv("LOGSTORE_DRIVER"), "database/sql driver name, i.e. sqlite3, mysql, postgres or sqlserver (env LOGSTORE_DRIVER)")
+	flags.StringVar(&conn.dsn, "dsn", os.Getenv("LOGSTORE_DSN"), "data source name of the database (env LOGSTORE_DSN)")
+	flags.StringVar(&conn.table, "table", "log", "name of the log table")
+	flags.BoolVar(&conn.debug, "debug", false, "print the SQL statements")
+
+	return conn
+}
+
+// open connects to the database and creates the store, the returned
+// function closes both
+func (conn *connection) open(options logstore.NewStoreOptions) (logstore.StoreInterface, func(), error) {
+	if conn.driver == "" || conn.dsn == "" {
+		return nil, nil, errors.New("-driver and -dsn are required")
+	}
+
+	if !slices.Contains(sql.Drivers(), conn.driver) {
+		return nil, nil, errors.New("unknown driver " + conn.driver + ", available drivers are " + strings.Join(sql.Drivers(), ", "))
+	}
+
+	db, err := sql.Open(conn.driver, conn.dsn)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	options.DB = db
+	options.LogTableName = conn.table
+	options.DebugEnabled = conn.debug
+
+	store, err := logstore.NewStore(options)
+
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	closeFunc := func() {
+		store.Close(context.Background())
+		db.Close()
+	}
+
+	return store, closeFunc, nil
+}
+
+// parseFlags parses the arguments, rejecting positional arguments
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() > 0 {
+		fmt.Fprintln(flags.Output(), "unexpected argument "+flags.Arg(0))
+		flags.Usage()
+		return errUsage
+	}
+
+	return nil
+}
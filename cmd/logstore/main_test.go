@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runCommand runs the tool against the SQLite database and returns the exit code and output
+func runCommand(t *testing.T, dsn string, args ...string) (int, string, string) {
+	t.Helper()
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	args = append(args, "-driver", "sqlite3", "-dsn", dsn)
+	code := run(context.Background(), args, stdout, stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func Test_CLI(t *testing.T) {
+	directory := t.TempDir()
+	dsn := filepath.Join(directory, "logs.db")
+
+	code, stdout, stderr := runCommand(t, dsn, "migrate", "-dry-run")
+	if code != 0 || !strings.Contains(stdout, `CREATE TABLE IF NOT EXISTS "log"`) {
+		t.Fatalf("Expected the migration SQL, received %d [%s] [%s]", code, stdout, stderr)
+	}
+
+	code, _, stderr = runCommand(t, dsn, "migrate")
+	if code != 0 {
+		t.Fatalf("Expected the migrations to be applied, received %d [%s]", code, stderr)
+	}
+
+	code, stdout, _ = runCommand(t, dsn, "migrate", "-status")
+	if code != 0 || !strings.Contains(stdout, "current version: 6") || strings.Contains(stdout, "pending") {
+		t.Fatalf("Expected no pending migrations, received %d [%s]", code, stdout)
+	}
+
+	input := filepath.Join(directory, "slog.jsonl")
+	lines := strings.Join([]string{
+		`{"time":"2024-01-02T03:04:05Z","level":"ERROR","msg":"disk full","request":{"id":"req-1"}}`,
+		`{"time":"2024-01-02T03:04:06Z","level":"INFO","msg":"retrying"}`,
+		`{"time":"2024-01-02T03:04:07Z","level":"WARN","msg":"disk almost full","request":{"id":"req-2"}}`,
+	}, "\n")
+
+	if err := os.WriteFile(input, []byte(lines), 0o644); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	code, stdout, stderr = runCommand(t, dsn, "import", "-file", input)
+	if code != 0 || !strings.Contains(stdout, "imported 3 logs") {
+		t.Fatalf("Expected 3 imported logs, received %d [%s] [%s]", code, stdout, stderr)
+	}
+
+	code, stdout, _ = runCommand(t, dsn, "count", "-min-level", "warning")
+	if code != 0 || strings.TrimSpace(stdout) != "2" {
+		t.Fatalf("Expected 2 warnings and above, received %d [%s]", code, stdout)
+	}
+
+	code, stdout, _ = runCommand(t, dsn, "query", "-json", "request.id=req-1")
+	if code != 0 || !strings.Contains(stdout, "ERROR disk full") || strings.Contains(stdout, "almost") {
+		t.Fatalf("Expected the log of the request, received %d [%s]", code, stdout)
+	}
+
+	code, stdout, _ = runCommand(t, dsn, "query", "-text", "disk", "-format", "jsonl", "-sort", "asc")
+	if code != 0 || len(strings.Split(strings.TrimSpace(stdout), "\n")) != 2 {
+		t.Fatalf("Expected 2 JSON lines, received %d [%s]", code, stdout)
+	}
+
+	code, stdout, _ = runCommand(t, dsn, "export", "-level", "ERROR,WARN", "-columns", "level,message", "-context-columns", "request.id")
+	expected := "level,message,request.id\nerror,disk full,req-1\nwarning,disk almost full,req-2\n"
+	if code != 0 || stdout != expected {
+		t.Fatalf("Expected CSV:\n%s\nreceived %d:\n%s", expected, code, stdout)
+	}
+
+	code, _, stderr = runCommand(t, dsn, "count", "-level", "verbose")
+	if code != 1 || !strings.Contains(stderr, "verbose") {
+		t.Fatalf("Expected an unknown level to be rejected, received %d [%s]", code, stderr)
+	}
+
+	code, stdout, _ = runCommand(t, dsn, "tail", "-n", "2")
+	if code != 0 || !strings.HasPrefix(stdout, "2024-01-02T03:04:06.000000Z INFO retrying") || strings.Count(stdout, "\n") != 2 {
+		t.Fatalf("Expected the 2 latest logs oldest first, received %d [%s]", code, stdout)
+	}
+
+	code, stdout, _ = runCommand(t, dsn, "tail", "-n", "0")
+	if code != 0 || stdout != "" {
+		t.Fatalf("Expected no logs for -n 0, received %d [%s]", code, stdout)
+	}
+
+	code, stdout, _ = runCommand(t, dsn, "purge", "-max-rows", "1")
+	if code != 0 || !strings.Contains(stdout, "deleted 2 logs") {
+		t.Fatalf("Expected 2 logs to be purged, received %d [%s]", code, stdout)
+	}
+
+	code, _, stderr = runCommand(t, dsn, "purge")
+	if code != 1 || !strings.Contains(stderr, "is required") {
+		t.Fatalf("Expected a purge without limits to fail, received %d [%s]", code, stderr)
+	}
+
+	code, _, _ = runCommand(t, dsn, "unknown")
+	if code != 2 {
+		t.Fatalf("Expected an unknown command to be a usage error, received %d", code)
+	}
+
+	stderrBuffer := &bytes.Buffer{}
+	code = run(context.Background(), []string{"count", "-driver", "oracle", "-dsn", dsn}, &bytes.Buffer{}, stderrBuffer)
+	if code != 1 || !strings.Contains(stderrBuffer.String(), "unknown driver oracle") {
+		t.Fatalf("Expected an unknown driver to be rejected, received %d [%s]", code, stderrBuffer.String())
+	}
+
+	for _, driver := range []string{"sqlite3", "mysql", "postgres", "sqlserver"} {
+		if !slices.Contains(sql.Drivers(), driver) {
+			t.Fatalf("Expected the %s driver to be registered, received %v", driver, sql.Drivers())
+		}
+	}
+}
+
+func Test_CLI_TailFollow(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "logs.db")
+
+	if code, _, stderr := runCommand(t, dsn, "migrate"); code != 0 {
+		t.Fatalf("Expected the migrations to be applied, received %d [%s]", code, stderr)
+	}
+
+	existing := filepath.Join(t.TempDir(), "existing.jsonl")
+	if err := os.WriteFile(existing, []byte(`{"level":"INFO","msg":"already there"}`), 0o644); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if code, _, stderr := runCommand(t, dsn, "import", "-file", existing); code != 0 {
+		t.Fatalf("Expected the log to be imported, received %d [%s]", code, stderr)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stdout := &bytes.Buffer{}
+	done := make(chan int)
+
+	// the buffer is read only after tail returns
+	go func() {
+		done <- run(ctx, []string{"tail", "-n", "0", "-f", "-interval", "10ms", "-driver", "sqlite3", "-dsn", dsn}, stdout, &bytes.Buffer{})
+	}()
+
+	input := filepath.Join(t.TempDir(), "slog.jsonl")
+	if err := os.WriteFile(input, []byte(`{"level":"INFO","msg":"arrived later"}`), 0o644); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if code, _, stderr := runCommand(t, dsn, "import", "-file", input); code != 0 {
+		t.Fatalf("Expected the log to be imported, received %d [%s]", code, stderr)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	if code := <-done; code != 0 {
+		t.Fatalf("Expected tail to stop when cancelled, received %d", code)
+	}
+
+	if strings.Count(stdout.String(), "INFO arrived later") != 1 || strings.Contains(stdout.String(), "already there") {
+		t.Fatalf("Expected only the new log to be printed once, received [%s]", stdout.String())
+	}
+}
+
+func Test_parseAge(t *testing.T) {
+	cases := map[string]time.Duration{
+		"90m": 90 * time.Minute,
+		"12h": 12 * time.Hour,
+		"7d":  7 * 24 * time.Hour,
+	}
+
+	for value, expected := range cases {
+		age, err := parseAge(value)
+		if err != nil || age != expected {
+			t.Fatalf("Expected %s to be %v, received %v %v", value, expected, age, err)
+		}
+	}
+
+	if _, err := parseAge("-1d"); err == nil {
+		t.Fatal("Expected a negative age to be rejected")
+	}
+}
@@ -1,7 +1,19 @@
 package logstore
 
 const COLUMN_CONTEXT = "context"
+const COLUMN_ENVIRONMENT = "environment"
+const COLUMN_HOST = "host"
 const COLUMN_ID = "id"
 const COLUMN_LEVEL = "level"
 const COLUMN_MESSAGE = "message"
+const COLUMN_REQUEST_ID = "request_id"
+const COLUMN_SERVICE = "service"
+const COLUMN_SEVERITY = "severity"
+const COLUMN_SOURCE_FILE = "source_file"
+const COLUMN_SOURCE_FUNCTION = "source_function"
+const COLUMN_SOURCE_LINE = "source_line"
+const COLUMN_SPAN_ID = "span_id"
 const COLUMN_TIME = "time"
+const COLUMN_TRACE_ID = "trace_id"
+const COLUMN_USER_ID = "user_id"
+const COLUMN_VERSION = "version"
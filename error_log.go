@@ -0,0 +1,117 @@
+package logstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// Context keys of the error details stored by LogError
+const (
+	ContextKeyError         = "error"
+	ContextKeyErrorType     = "error_type"
+	ContextKeyErrorChain    = "error_chain"
+	ContextKeyRootCauseType = "root_cause_type"
+)
+
+// LogError adds an error log for the error. The message, the chain of
+// wrapped errors (errors.Unwrap and errors.Join) with their concrete
+// types, the type of the root cause and the caller stack trace are stored
+// in the context, next to the fields given as key value pairs:
+//
+//	logStore.LogError(err, "user_id", userID, "attempt", 3)
+//
+// Nothing is logged for a nil error.
+func (st *storeImplementation) LogError(err error, fields ...any) error {
+	return st.logError(context.Background(), err, fields)
+}
+
+// LogErrorCtx is LogError honouring the ctx
+func (st *storeImplementation) LogErrorCtx(ctx context.Context, err error, fields ...any) error {
+	return st.logError(ctx, err, fields)
+}
+
+func (st *storeImplementation) logError(ctx context.Context, err error, fields []any) error {
+	if err == nil {
+		return nil
+	}
+
+	values := errorFields(fields)
+
+	chain := []map[string]string{}
+	for _, chained := range append([]error{err}, unwrapErrors(err)...) {
+		chain = append(chain, map[string]string{
+			"message": chained.Error(),
+			"type":    fmt.Sprintf("%T", chained),
+		})
+	}
+
+	values[ContextKeyError] = err.Error()
+	values[ContextKeyErrorType] = fmt.Sprintf("%T", err)
+	values[ContextKeyErrorChain] = chain
+	values[ContextKeyRootCauseType] = fmt.Sprintf("%T", rootCause(err))
+	values[ContextKeyStack] = callerStack()
+
+	contextBytes, jsonErr := json.Marshal(values)
+
+	if jsonErr != nil {
+		log.Println(jsonErr)
+		contextBytes = []byte("JSON encode error")
+	}
+
+	logEntry := Log{
+		Level:   LevelError,
+		Message: err.Error(),
+		Context: string(contextBytes),
+	}
+
+	return st.LogContext(ctx, &logEntry)
+}
+
+// errorFields converts key value pairs into a map. As slog does, a key
+// that is not a string, or the last key without a value, is stored as the
+// value of "!BADKEY" and the pairs continue with the next argument.
+func errorFields(fields []any) map[string]any {
+	values := map[string]any{}
+
+	for i := 0; i < len(fields); i++ {
+		key, ok := fields[i].(string)
+
+		if !ok || i+1 >= len(fields) {
+			values["!BADKEY"] = encodeAny(fields[i])
+			continue
+		}
+
+		values[key] = encodeAny(fields[i+1])
+		i++
+	}
+
+	return values
+}
+
+// rootCause returns the innermost wrapped error, following
+// the first error of joined errors
+func rootCause(err error) error {
+	for {
+		var next error
+
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				if inner != nil {
+					next = inner
+					break
+				}
+			}
+		case interface{ Unwrap() error }:
+			next = e.Unwrap()
+		}
+
+		if next == nil {
+			return err
+		}
+
+		err = next
+	}
+}
@@ -0,0 +1,461 @@
+package logstore
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormat is the format logs are exported in
+type ExportFormat string
+
+const (
+	// ExportFormatCSV writes a header and a row per log
+	ExportFormatCSV ExportFormat = "csv"
+
+	// ExportFormatJSONL writes a JSON object per line, as Archive does
+	ExportFormatJSONL ExportFormat = "jsonl"
+
+	// ExportFormatNDJSON is ExportFormatJSONL under its other name
+	ExportFormatNDJSON ExportFormat = "ndjson"
+
+	// ExportFormatText writes a human readable line per log
+	ExportFormatText ExportFormat = "text"
+)
+
+// exportTimeLayout is the layout of the times in CSV and text exports
+const exportTimeLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+// ExportOptions define the options of an export
+type ExportOptions struct {
+	// Format defaults to ExportFormatJSONL
+	Format ExportFormat
+
+	// Columns are the log columns of a CSV export, i.e. COLUMN_TIME,
+	// defaults to id, time, level and message, and context unless
+	// context columns are given
+	Columns []string
+
+	// ContextColumns are the context keys flattened into columns of a CSV
+	// export, nested keys are given as paths, i.e. "request.id"
+	ContextColumns []string
+
+	// CSVFormulasAllowed writes the CSV cells as they are. By default the
+	// cells starting with =, +, -, @, a tab or a carriage return are
+	// prefixed with ', so spreadsheets do not evaluate them as formulas.
+	CSVFormulasAllowed bool
+}
+
+// exportColumns are the log columns that can be exported
+var exportColumns = []string{
+	COLUMN_ID,
+	COLUMN_TIME,
+	COLUMN_LEVEL,
+	COLUMN_SEVERITY,
+	COLUMN_MESSAGE,
+	COLUMN_CONTEXT,
+	COLUMN_SOURCE_FILE,
+	COLUMN_SOURCE_LINE,
+	COLUMN_SOURCE_FUNCTION,
+	COLUMN_SERVICE,
+	COLUMN_HOST,
+	COLUMN_ENVIRONMENT,
+	COLUMN_VERSION,
+	COLUMN_REQUEST_ID,
+	COLUMN_TRACE_ID,
+	COLUMN_SPAN_ID,
+	COLUMN_USER_ID,
+}
+
+// Export writes the logs matching the query to the writer, row by row as
+// they are read, so no more than one log is held in memory. It returns
+// the number of exported logs.
+func (st *storeImplementation) Export(ctx context.Context, query LogQueryInterface, writer io.Writer, options ExportOptions) (int64, error) {
+	if query == nil {
+		query = LogQuery()
+	}
+
+	if err := st.validateLogQuery(ctx, query); err != nil {
+		return 0, err
+	}
+
+	exporter, err := newLogExporter(writer, options)
+
+	if err != nil {
+		return 0, err
+	}
+
+	sqlStr, sqlParams, err := st.logSelectQuery(query).
+		Select(logSelectColumns()...).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := st.db.QueryContext(ctx, sqlStr, sqlParams...)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	count := int64(0)
+
+	for rows.Next() {
+		logEntry, err := scanLog(rows)
+
+		if err != nil {
+			return count, err
+		}
+
+		if err := exporter.write(logEntry); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+
+	return count, exporter.flush()
+}
+
+// ExportLogs writes the logs to the writer in the export format,
+// i.e. to print logs already read with LogList
+func ExportLogs(writer io.Writer, logs []Log, options ExportOptions) error {
+	exporter, err := newLogExporter(writer, options)
+
+	if err != nil {
+		return err
+	}
+
+	for i := range logs {
+		if err := exporter.write(&logs[i]); err != nil {
+			return err
+		}
+	}
+
+	return exporter.flush()
+}
+
+// logExporter writes logs in an export format
+type logExporter struct {
+	options   ExportOptions
+	buffered  *bufio.Writer
+	csvWriter *csv.Writer
+}
+
+// newLogExporter creates an exporter, writing the CSV header
+func newLogExporter(writer io.Writer, options ExportOptions) (*logExporter, error) {
+	if options.Format == "" {
+		options.Format = ExportFormatJSONL
+	}
+
+	exporter := &logExporter{
+		options:  options,
+		buffered: bufio.NewWriter(writer),
+	}
+
+	switch options.Format {
+	case ExportFormatJSONL, ExportFormatNDJSON, ExportFormatText:
+		return exporter, nil
+	case ExportFormatCSV:
+	default:
+		return nil, errors.New("log store: unsupported export format " + string(options.Format))
+	}
+
+	if len(exporter.options.Columns) < 1 {
+		exporter.options.Columns = []string{COLUMN_ID, COLUMN_TIME, COLUMN_LEVEL, COLUMN_MESSAGE}
+
+		if len(options.ContextColumns) < 1 {
+			exporter.options.Columns = append(exporter.options.Columns, COLUMN_CONTEXT)
+		}
+	}
+
+	for _, column := range exporter.options.Columns {
+		if !slices.Contains(exportColumns, column) {
+			return nil, errors.New("log store: unsupported export column " + column)
+		}
+	}
+
+	exporter.csvWriter = csv.NewWriter(exporter.buffered)
+
+	header := append(append([]string{}, exporter.options.Columns...), options.ContextColumns...)
+
+	if err := exporter.writeCSVRecord(header); err != nil {
+		return nil, err
+	}
+
+	return exporter, nil
+}
+
+// write writes the log in the export format
+func (exporter *logExporter) write(logEntry *Log) error {
+	switch exporter.options.Format {
+	case ExportFormatCSV:
+		return exporter.writeCSV(logEntry)
+	case ExportFormatText:
+		return exporter.writeText(logEntry)
+	}
+
+	line, err := json.Marshal(logEntry)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := exporter.buffered.Write(line); err != nil {
+		return err
+	}
+
+	return exporter.buffered.WriteByte('\n')
+}
+
+// writeCSV writes the columns and the context columns of the log as a CSV row
+func (exporter *logExporter) writeCSV(logEntry *Log) error {
+	record := make([]string, 0, len(exporter.options.Columns)+len(exporter.options.ContextColumns))
+
+	for _, column := range exporter.options.Columns {
+		record = append(record, logColumnValue(logEntry, column))
+	}
+
+	if len(exporter.options.ContextColumns) > 0 {
+		values := contextObject(logEntry.Context)
+
+		for _, path := range exporter.options.ContextColumns {
+			record = append(record, contextPathValue(values, path))
+		}
+	}
+
+	return exporter.writeCSVRecord(record)
+}
+
+// csvFormulaPrefixes are the first characters of the cells spreadsheets evaluate as formulas
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// writeCSVRecord writes the record, escaping the cells read as formulas
+// unless formulas are allowed
+func (exporter *logExporter) writeCSVRecord(record []string) error {
+	if !exporter.options.CSVFormulasAllowed {
+		for i, cell := range record {
+			if cell != "" && strings.ContainsRune(csvFormulaPrefixes, rune(cell[0])) {
+				record[i] = "'" + cell
+			}
+		}
+	}
+
+	return exporter.csvWriter.Write(record)
+}
+
+// writeText writes the log as "time LEVEL message key=value ...",
+// the context keys flattened with dotted paths and sorted
+func (exporter *logExporter) writeText(logEntry *Log) error {
+	line := strings.Builder{}
+
+	line.WriteString(logColumnValue(logEntry, COLUMN_TIME))
+	line.WriteString(" ")
+	line.WriteString(strings.ToUpper(logEntry.Level))
+	line.WriteString(" ")
+	line.WriteString(logEntry.Message)
+
+	values := contextObject(logEntry.Context)
+
+	// contexts that are not JSON objects are written as they are
+	if rawContext := strings.TrimSpace(logEntry.Context); values == nil && rawContext != "" && rawContext != "null" {
+		line.WriteString(" context=")
+		line.WriteString(strconv.Quote(logEntry.Context))
+	}
+
+	flattened := map[string]any{}
+	flattenContext(flattened, "", values)
+
+	keys := make([]string, 0, len(flattened))
+	for key := range flattened {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		line.WriteString(" ")
+		line.WriteString(key)
+		line.WriteString("=")
+		line.WriteString(quoteTextValue(textValue(flattened[key])))
+	}
+
+	line.WriteString("\n")
+
+	_, err := exporter.buffered.WriteString(line.String())
+
+	return err
+}
+
+// flush writes the buffered output
+func (exporter *logExporter) flush() error {
+	if exporter.csvWriter != nil {
+		exporter.csvWriter.Flush()
+
+		if err := exporter.csvWriter.Error(); err != nil {
+			return err
+		}
+	}
+
+	return exporter.buffered.Flush()
+}
+
+// flattenContext adds the values of the context to the flat map,
+// nested objects under dotted keys
+func flattenContext(flat map[string]any, prefix string, values map[string]any) {
+	for key, value := range values {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if nested, ok := value.(map[string]any); ok {
+			flattenContext(flat, key, nested)
+			continue
+		}
+
+		flat[key] = value
+	}
+}
+
+// quoteTextValue quotes values that would be ambiguous in a text line
+func quoteTextValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\n\t") {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
+
+// logColumnValue returns the value of the log column as text
+func logColumnValue(logEntry *Log, column string) string {
+	switch column {
+	case COLUMN_ID:
+		return logEntry.ID
+	case COLUMN_TIME:
+		if logEntry.Time == nil {
+			return ""
+		}
+		return logEntry.Time.UTC().Format(exportTimeLayout)
+	case COLUMN_LEVEL:
+		return logEntry.Level
+	case COLUMN_SEVERITY:
+		return strconv.Itoa(int(logEntry.Severity))
+	case COLUMN_MESSAGE:
+		return logEntry.Message
+	case COLUMN_CONTEXT:
+		return logEntry.Context
+	case COLUMN_SOURCE_FILE:
+		return logEntry.SourceFile
+	case COLUMN_SOURCE_LINE:
+		if logEntry.SourceLine == 0 {
+			return ""
+		}
+		return strconv.Itoa(logEntry.SourceLine)
+	case COLUMN_SOURCE_FUNCTION:
+		return logEntry.SourceFunction
+	case COLUMN_SERVICE:
+		return logEntry.Service
+	case COLUMN_HOST:
+		return logEntry.Host
+	case COLUMN_ENVIRONMENT:
+		return logEntry.Environment
+	case COLUMN_VERSION:
+		return logEntry.Version
+	case COLUMN_REQUEST_ID:
+		return logEntry.RequestID
+	case COLUMN_TRACE_ID:
+		return logEntry.TraceID
+	case COLUMN_SPAN_ID:
+		return logEntry.SpanID
+	case COLUMN_USER_ID:
+		return logEntry.UserID
+	}
+
+	return ""
+}
+
+// contextObject returns the context as a map, nil if it is not a JSON object
+func contextObject(logContext string) map[string]any {
+	values := map[string]any{}
+
+	if err := json.Unmarshal([]byte(logContext), &values); err != nil {
+		return nil
+	}
+
+	return values
+}
+
+// contextPathValue returns the value at the dotted path of the context as text,
+// keys containing dots are matched before nested keys
+func contextPathValue(values map[string]any, path string) string {
+	if values == nil {
+		return ""
+	}
+
+	if value, ok := values[path]; ok {
+		return textValue(value)
+	}
+
+	for i := strings.Index(path, "."); i >= 0; i = nextDot(path, i) {
+		nested, ok := values[path[:i]].(map[string]any)
+
+		if ok {
+			if value := contextPathValue(nested, path[i+1:]); value != "" {
+				return value
+			}
+		}
+	}
+
+	return ""
+}
+
+// nextDot returns the index of the dot after the index, -1 if none
+func nextDot(path string, index int) int {
+	next := strings.Index(path[index+1:], ".")
+
+	if next < 0 {
+		return -1
+	}
+
+	return index + 1 + next
+}
+
+// textValue returns the value as text, strings as they are and anything else as JSON
+func textValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.UTC().Format(exportTimeLayout)
+	}
+
+	valueBytes, err := json.Marshal(value)
+
+	if err != nil {
+		return ""
+	}
+
+	return string(valueBytes)
+}
@@ -0,0 +1,36 @@
+package logstore
+
+import (
+	"context"
+	"time"
+)
+
+// FatalBehavior defines what the Fatal methods do after logging
+type FatalBehavior int
+
+const (
+	// FatalBehaviorExit flushes the queued logs and exits with code 1
+	FatalBehaviorExit FatalBehavior = iota
+
+	// FatalBehaviorLogOnly only logs, the caller decides how to stop
+	FatalBehaviorLogOnly
+)
+
+// FatalFlushTimeout is how long a fatal log waits for the queued logs
+// to be written before exiting
+const FatalFlushTimeout = 5 * time.Second
+
+// exitAfterFatal flushes the queued logs and calls the exit function,
+// as configured by the fatal behavior
+func (st *storeImplementation) exitAfterFatal() {
+	if st.fatalBehavior == FatalBehaviorLogOnly {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), FatalFlushTimeout)
+	defer cancel()
+
+	st.Flush(ctx)
+
+	st.exitFunc(1)
+}
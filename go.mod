@@ -5,17 +5,24 @@ go 1.23.3
 require (
 	github.com/doug-martin/goqu/v9 v9.19.0
 	github.com/dromara/carbon/v2 v2.6.1
+	github.com/go-sql-driver/mysql v1.8.1
 	github.com/gouniverse/sb v0.8.0
 	github.com/gouniverse/uid v1.5.0
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.25
+	github.com/microsoft/go-mssqldb v1.7.2
 )
 
 require (
+	filippo.io/edwards25519 v1.1.0 // indirect
 	github.com/georgysavva/scany v1.2.3 // indirect
+	github.com/golang-sql/civil v0.0.0-20220223132316-b832511892a9 // indirect
+	github.com/golang-sql/sqlexp v0.1.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/gouniverse/base v0.9.0 // indirect
 	github.com/gouniverse/maputils v0.7.0 // indirect
 	github.com/samber/lo v1.49.1 // indirect
 	github.com/spf13/cast v1.7.1 // indirect
+	golang.org/x/crypto v0.35.0 // indirect
 	golang.org/x/text v0.23.0 // indirect
 )
@@ -0,0 +1,333 @@
+package logstore
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// DefaultImportBatchSize is the number of logs inserted at once when importing
+const DefaultImportBatchSize = 500
+
+// ImportOptions define the options of an import
+type ImportOptions struct {
+	// BatchSize is the number of logs inserted at once,
+	// defaults to DefaultImportBatchSize
+	BatchSize int
+
+	// Progress is called after each batch with the totals so far
+	Progress func(progress ImportProgress)
+}
+
+// ImportProgress reports the progress of an import
+type ImportProgress struct {
+	// Lines is the number of lines read, blank lines included
+	Lines int64
+
+	// Imported is the number of logs inserted
+	Imported int64
+
+	// Skipped is the number of logs skipped, as their ID exists already
+	Skipped int64
+}
+
+// Import reads JSON Lines and inserts the logs in batches. Each line is
+// either a log as written by Archive, keeping its ID, or a record written
+// by slog.NewJSONHandler, whose time, level and msg become the time, level
+// and message of the log and whose other keys become the context.
+// Logs with an ID that exists already and logs below the minimum level are
+// skipped. Gzip compressed input is decompressed.
+func (st *storeImplementation) Import(ctx context.Context, reader io.Reader, options ImportOptions) (ImportProgress, error) {
+	progress := ImportProgress{}
+
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultImportBatchSize
+	}
+
+	lines, err := importReader(reader)
+
+	if err != nil {
+		return progress, err
+	}
+
+	batch := make([]*Log, 0, options.BatchSize)
+
+	insertBatch := func() error {
+		if len(batch) < 1 {
+			return nil
+		}
+
+		imported, skipped, err := st.importBatch(ctx, batch)
+
+		progress.Imported += imported
+		progress.Skipped += skipped
+		batch = make([]*Log, 0, options.BatchSize)
+
+		if err != nil {
+			return err
+		}
+
+		if options.Progress != nil {
+			options.Progress(progress)
+		}
+
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+
+		line, readErr := lines.ReadBytes('\n')
+
+		if readErr != nil && readErr != io.EOF {
+			return progress, readErr
+		}
+
+		if len(line) > 0 {
+			progress.Lines++
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			logEntry, err := parseImportLine(line)
+
+			if err != nil {
+				return progress, errors.New("log store: import line " + strconv.FormatInt(progress.Lines, 10) + ": " + err.Error())
+			}
+
+			batch = append(batch, logEntry)
+
+			if len(batch) >= options.BatchSize {
+				if err := insertBatch(); err != nil {
+					return progress, err
+				}
+			}
+		}
+
+		if readErr == io.EOF {
+			return progress, insertBatch()
+		}
+	}
+}
+
+// importReader returns a buffered reader of the input, decompressing gzip input
+func importReader(reader io.Reader) (*bufio.Reader, error) {
+	buffered := bufio.NewReader(reader)
+
+	magic, err := buffered.Peek(2)
+
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(buffered)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return bufio.NewReader(gzipReader), nil
+	}
+
+	return buffered, nil
+}
+
+// importBatch inserts the logs of the batch whose ID does not exist yet,
+// returning the number of inserted and skipped logs
+func (st *storeImplementation) importBatch(ctx context.Context, batch []*Log) (int64, int64, error) {
+	ids := []string{}
+	for _, logEntry := range batch {
+		if logEntry.ID != "" {
+			ids = append(ids, logEntry.ID)
+		}
+	}
+
+	existing, err := st.existingIDs(ctx, ids)
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	inserts := []*Log{}
+
+	for _, logEntry := range batch {
+		// logs below the minimum level are dropped by LogBatch
+		if !st.Enabled(logEntry.Level) {
+			continue
+		}
+
+		if logEntry.ID != "" {
+			// duplicates within the batch are skipped too
+			if existing[logEntry.ID] {
+				continue
+			}
+			existing[logEntry.ID] = true
+		}
+
+		inserts = append(inserts, logEntry)
+	}
+
+	skipped := int64(len(batch) - len(inserts))
+
+	if err := ctx.Err(); err != nil {
+		return 0, skipped, err
+	}
+
+	if len(inserts) < 1 {
+		return 0, skipped, nil
+	}
+
+	if err := st.LogBatch(inserts); err != nil {
+		return 0, skipped, err
+	}
+
+	return int64(len(inserts)), skipped, nil
+}
+
+// existingIDs returns which of the IDs are stored already, looking them up
+// in chunks within the bound parameter limit of the database
+func (st *storeImplementation) existingIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	existing := map[string]bool{}
+	chunkSize := st.maxParams() - 1
+
+	for start := 0; start < len(ids); start += chunkSize {
+		end := min(start+chunkSize, len(ids))
+
+		sqlStr, sqlParams, err := st.dialect().
+			From(st.logTableName).
+			Select(COLUMN_ID).
+			Where(goqu.C(COLUMN_ID).In(ids[start:end])).
+			Prepared(true).
+			ToSQL()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		rows, err := st.db.QueryContext(ctx, sqlStr, sqlParams...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var id string
+
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			existing[id] = true
+		}
+
+		err = rows.Err()
+		rows.Close()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return existing, nil
+}
+
+// parseImportLine parses a line written by Archive or by slog.NewJSONHandler,
+// the latter is recognised by its msg key
+func parseImportLine(line []byte) (*Log, error) {
+	fields := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, err
+	}
+
+	if _, ok := fields[slog.MessageKey]; ok {
+		return parseSlogLine(fields)
+	}
+
+	logEntry := &Log{}
+
+	if err := json.Unmarshal(line, logEntry); err != nil {
+		return nil, err
+	}
+
+	if logEntry.Level == "" {
+		return nil, errors.New("level is missing")
+	}
+
+	return logEntry, nil
+}
+
+// parseSlogLine converts the fields of a slog JSON record into a log,
+// storing the numeric slog level in the context as the slog handler does
+func parseSlogLine(fields map[string]json.RawMessage) (*Log, error) {
+	logEntry := &Log{}
+
+	if err := json.Unmarshal(fields[slog.MessageKey], &logEntry.Message); err != nil {
+		return nil, errors.New("msg must be a string")
+	}
+
+	delete(fields, slog.MessageKey)
+
+	level := slog.LevelInfo
+
+	if raw, ok := fields[slog.LevelKey]; ok {
+		var text string
+
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return nil, errors.New("level must be a string")
+		}
+
+		if err := level.UnmarshalText([]byte(text)); err != nil {
+			return nil, err
+		}
+
+		delete(fields, slog.LevelKey)
+	}
+
+	logEntry.Level = slogLevelToStoreLevel(level)
+
+	if raw, ok := fields[slog.TimeKey]; ok {
+		var text string
+
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return nil, errors.New("time must be a string")
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(text))
+
+		if err != nil {
+			return nil, err
+		}
+
+		logEntry.Time = &t
+
+		delete(fields, slog.TimeKey)
+	}
+
+	levelBytes, _ := json.Marshal(int(level))
+	fields[SlogLevelKey] = levelBytes
+
+	contextBytes, err := json.Marshal(fields)
+
+	if err != nil {
+		return nil, err
+	}
+
+	logEntry.Context = string(contextBytes)
+
+	return logEntry, nil
+}
@@ -0,0 +1,103 @@
+package logstore
+
+import (
+	"log"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/gouniverse/sb"
+)
+
+// logIndexes returns the columns of the indexes of the log table,
+// serving the time range, level and correlation queries
+func logIndexes() [][]string {
+	return [][]string{
+		{COLUMN_TIME},
+		{COLUMN_LEVEL, COLUMN_TIME},
+		{COLUMN_SEVERITY, COLUMN_TIME},
+		{COLUMN_SERVICE, COLUMN_TIME},
+		{COLUMN_REQUEST_ID},
+		{COLUMN_TRACE_ID},
+		{COLUMN_USER_ID},
+	}
+}
+
+// indexName returns the name of the index on the columns,
+// prefixed with the table name as PostgreSQL index names are per schema
+func (st *storeImplementation) indexName(columns []string) string {
+	table := strings.ReplaceAll(st.logTableName, ".", "_")
+	return table + "_" + strings.Join(columns, "_") + "_idx"
+}
+
+// sqlCreateMissingIndexes returns the statements creating the indexes the log table does not have
+func (st *storeImplementation) sqlCreateMissingIndexes() ([]string, error) {
+	statements := []string{}
+
+	for _, columns := range logIndexes() {
+		name := st.indexName(columns)
+
+		exists, err := st.indexExists(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			continue
+		}
+
+		sqlStr := sb.NewBuilder(st.dbDriverName).
+			Table(st.logTableName).
+			CreateIndex(name, columns...)
+
+		statements = append(statements, sqlStr)
+	}
+
+	return statements, nil
+}
+
+// indexExists returns whether the index exists, looked up in the catalog of the database
+func (st *storeImplementation) indexExists(name string) (bool, error) {
+	var q *goqu.SelectDataset
+
+	switch st.dbDriverName {
+	case sb.DIALECT_SQLITE:
+		q = st.dialect().From("sqlite_master").
+			Where(goqu.C("type").Eq("index"), goqu.C("name").Eq(name))
+	case sb.DIALECT_MYSQL:
+		q = st.dialect().From(goqu.S("information_schema").Table("statistics")).
+			Where(
+				goqu.C("table_schema").Eq(goqu.L("DATABASE()")),
+				goqu.C("index_name").Eq(name),
+			)
+	case sb.DIALECT_POSTGRES:
+		q = st.dialect().From("pg_indexes").
+			Where(
+				goqu.C("schemaname").Eq(goqu.L("current_schema()")),
+				goqu.C("indexname").Eq(name),
+			)
+	case sb.DIALECT_MSSQL:
+		q = st.dialect().From(goqu.S("sys").Table("indexes")).
+			Where(goqu.C("name").Eq(name))
+	default:
+		return false, nil
+	}
+
+	sqlStr, sqlParams, err := q.Select(goqu.COUNT(goqu.Star())).Prepared(true).ToSQL()
+
+	if err != nil {
+		return false, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	var count int64
+
+	if err := st.db.QueryRow(sqlStr, sqlParams...).Scan(&count); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
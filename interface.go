@@ -1,65 +1,161 @@
 package logstore
 
 import (
+	"context"
 	"errors"
+	"io"
 )
 
 // Errors
 var (
 	ErrLogTableNameRequired = errors.New("log store: logTableName is required")
 	ErrDBRequired           = errors.New("log store: DB is required")
+	ErrStoreClosed          = errors.New("log store: store is closed")
 )
 
 // StoreInterface defines the interface for a log store
 type StoreInterface interface {
-	// AutoMigrate creates the necessary database tables
+	// AutoMigrate creates the necessary database tables and applies the pending schema migrations
 	AutoMigrate() error
 
+	// MigrateDryRun returns the SQL statements AutoMigrate would execute
+	MigrateDryRun() ([]string, error)
+
+	// MigrationStatus reports the current and the latest schema version
+	MigrationStatus() (MigrationStatus, error)
+
+	// Close writes any queued log entries and stops the background writer and janitor
+	Close(ctx context.Context) error
+
+	// Enabled returns whether log entries of the level are stored
+	Enabled(level string) bool
+
+	// MinLevel returns the minimum level stored
+	MinLevel() string
+
+	// SetMinLevel changes the minimum level stored, log entries below it are dropped
+	SetMinLevel(level string) error
+
 	// EnableDebug enables or disables debug mode
 	EnableDebug(debug bool)
 
 	// Log adds a log entry
 	Log(logEntry *Log) error
 
+	// LogBatch adds many log entries using multi-row inserts inside one transaction
+	LogBatch(logEntries []*Log) error
+
+	// LogContext adds a log entry, honouring the ctx and adding the context extractor values
+	LogContext(ctx context.Context, logEntry *Log) error
+
+	// LogCount returns the number of log entries matching the query
+	LogCount(query LogQueryInterface) (int64, error)
+
+	// LogDelete deletes a log entry
+	LogDelete(logEntry *Log) error
+
+	// Archive writes the logs matching the query to the archive writer and,
+	// once the written archive is verified, deletes them
+	Archive(ctx context.Context, query LogQueryInterface, writer *ArchiveWriter) (ArchiveResult, error)
+
+	// Export writes the logs matching the query to the writer as CSV, JSON Lines or text
+	Export(ctx context.Context, query LogQueryInterface, writer io.Writer, options ExportOptions) (int64, error)
+
+	// Import inserts the logs of JSON Lines written by Archive or slog.NewJSONHandler,
+	// skipping the logs whose ID exists already
+	Import(ctx context.Context, reader io.Reader, options ImportOptions) (ImportProgress, error)
+
+	// PurgeExpired deletes the logs expired by the retention policy, returning their number
+	PurgeExpired(ctx context.Context) (int64, error)
+
+	// LogDeleteByID deletes a log entry by ID
+	LogDeleteByID(id string) error
+
+	// LogFindByID returns the log entry with the given ID, or nil if not found
+	LogFindByID(id string) (*Log, error)
+
+	// LogList returns the log entries matching the query
+	LogList(query LogQueryInterface) ([]Log, error)
+
+	// LogListByCursor returns a page of log entries matching the query, starting at the cursor
+	LogListByCursor(query LogQueryInterface, cursor string) (LogPage, error)
+
 	// Debug adds a debug log
 	Debug(message string) error
 
 	// DebugWithContext adds a debug log with context data
-	DebugWithContext(message string, context interface{}) error
+	DebugWithContext(message string, context interface{}, opts ...LogOption) error
+
+	// DebugCtx adds a debug log with optional context data, honouring the ctx
+	DebugCtx(ctx context.Context, message string, data interface{}, opts ...LogOption) error
 
 	// Error adds an error log
 	Error(message string) error
 
 	// ErrorWithContext adds an error log with context data
-	ErrorWithContext(message string, context interface{}) error
+	ErrorWithContext(message string, context interface{}, opts ...LogOption) error
+
+	// ErrorCtx adds an error log with optional context data, honouring the ctx
+	ErrorCtx(ctx context.Context, message string, data interface{}, opts ...LogOption) error
+
+	// LogError adds an error log for the error, with its wrapped chain, types and stack trace
+	LogError(err error, fields ...any) error
+
+	// LogErrorCtx adds an error log for the error honouring the ctx
+	LogErrorCtx(ctx context.Context, err error, fields ...any) error
 
 	// Fatal adds a fatal log
 	Fatal(message string) error
 
 	// FatalWithContext adds a fatal log with context data
-	FatalWithContext(message string, context interface{}) error
+	FatalWithContext(message string, context interface{}, opts ...LogOption) error
+
+	// FatalCtx adds a fatal log with optional context data, honouring the ctx
+	FatalCtx(ctx context.Context, message string, data interface{}, opts ...LogOption) error
+
+	// Flush waits until all queued log entries are written
+	Flush(ctx context.Context) error
 
 	// Info adds an info log
 	Info(message string) error
 
 	// InfoWithContext adds an info log with context data
-	InfoWithContext(message string, context interface{}) error
+	InfoWithContext(message string, context interface{}, opts ...LogOption) error
+
+	// InfoCtx adds an info log with optional context data, honouring the ctx
+	InfoCtx(ctx context.Context, message string, data interface{}, opts ...LogOption) error
 
 	// Panic adds a panic log and calls panic(message) after logging
 	Panic(message string)
 
 	// PanicWithContext adds a panic log with context data and calls panic(message) after logging
-	PanicWithContext(message string, context interface{})
+	PanicWithContext(message string, context interface{}, opts ...LogOption)
+
+	// PanicCtx adds a panic log with optional context data, honouring the ctx, and calls panic(message) after logging
+	PanicCtx(ctx context.Context, message string, data interface{}, opts ...LogOption)
+
+	// Recover logs a recovered panic with its stack trace, use as defer store.Recover(ctx)
+	Recover(ctx context.Context)
+
+	// RecoverAndRepanic logs a recovered panic with its stack trace and panics again,
+	// use as defer store.RecoverAndRepanic(ctx)
+	RecoverAndRepanic(ctx context.Context)
 
 	// Trace adds a trace log
 	Trace(message string) error
 
 	// TraceWithContext adds a trace log with context data
-	TraceWithContext(message string, context interface{}) error
+	TraceWithContext(message string, context interface{}, opts ...LogOption) error
+
+	// TraceCtx adds a trace log with optional context data, honouring the ctx
+	TraceCtx(ctx context.Context, message string, data interface{}, opts ...LogOption) error
 
 	// Warn adds a warn log
 	Warn(message string) error
 
 	// WarnWithContext adds a warn log with context data
-	WarnWithContext(message string, context interface{}) error
+	WarnWithContext(message string, context interface{}, opts ...LogOption) error
+
+	// WarnCtx adds a warn log with optional context data, honouring the ctx
+	WarnCtx(ctx context.Context, message string, data interface{}, opts ...LogOption) error
 }
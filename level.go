@@ -0,0 +1,86 @@
+package logstore
+
+import (
+	"errors"
+	"strings"
+)
+
+// Level is the numeric severity of a log level, higher is more severe
+type Level int
+
+// Severities of the log levels
+const (
+	SeverityTrace   Level = 1
+	SeverityDebug   Level = 2
+	SeverityInfo    Level = 3
+	SeverityWarning Level = 4
+	SeverityError   Level = 5
+	SeverityFatal   Level = 6
+	SeverityPanic   Level = 7
+)
+
+// levelNames maps the severities to the level names stored in the level column
+var levelNames = map[Level]string{
+	SeverityTrace:   LevelTrace,
+	SeverityDebug:   LevelDebug,
+	SeverityInfo:    LevelInfo,
+	SeverityWarning: LevelWarning,
+	SeverityError:   LevelError,
+	SeverityFatal:   LevelFatal,
+	SeverityPanic:   LevelPanic,
+}
+
+// levelAliases maps the accepted level names (lower case) to their severity
+var levelAliases = map[string]Level{
+	"trace":     SeverityTrace,
+	"debug":     SeverityDebug,
+	"info":      SeverityInfo,
+	"notice":    SeverityInfo,
+	"warn":      SeverityWarning,
+	"warning":   SeverityWarning,
+	"err":       SeverityError,
+	"error":     SeverityError,
+	"fatal":     SeverityFatal,
+	"crit":      SeverityFatal,
+	"critical":  SeverityFatal,
+	"panic":     SeverityPanic,
+	"emerg":     SeverityPanic,
+	"emergency": SeverityPanic,
+}
+
+// ParseLevel returns the severity of a level name, case insensitive,
+// accepting common aliases such as "WARN", "err", "crit" and "emerg"
+func ParseLevel(level string) (Level, error) {
+	severity, ok := levelAliases[strings.ToLower(strings.TrimSpace(level))]
+
+	if !ok {
+		return 0, errors.New("log store: unknown level " + level)
+	}
+
+	return severity, nil
+}
+
+// String returns the level name as stored in the level column,
+// i.e. "warning" for SeverityWarning
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// IsValid returns whether the level is one of the known severities
+func (l Level) IsValid() bool {
+	_, ok := levelNames[l]
+	return ok
+}
+
+// AtLeast returns whether the level is as severe as or more severe than the other
+func (l Level) AtLeast(other Level) bool {
+	return l >= other
+}
+
+// Below returns whether the level is less severe than the other
+func (l Level) Below(other Level) bool {
+	return l < other
+}
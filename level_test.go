@@ -0,0 +1,50 @@
+package logstore
+
+import "testing"
+
+func Test_ParseLevel(t *testing.T) {
+	cases := map[string]Level{
+		"trace":   SeverityTrace,
+		"DEBUG":   SeverityDebug,
+		"info":    SeverityInfo,
+		"WARN":    SeverityWarning,
+		"warning": SeverityWarning,
+		"err":     SeverityError,
+		"Error":   SeverityError,
+		"crit":    SeverityFatal,
+		"fatal":   SeverityFatal,
+		"emerg":   SeverityPanic,
+		" panic ": SeverityPanic,
+		"notice":  SeverityInfo,
+	}
+
+	for name, expected := range cases {
+		level, err := ParseLevel(name)
+		if err != nil {
+			t.Fatalf("Unexpected error for [%s]: %s", name, err.Error())
+		}
+
+		if level != expected {
+			t.Fatalf("Expected [%s] to parse as [%v], received [%v]", name, expected, level)
+		}
+	}
+
+	_, err := ParseLevel("verbose")
+	if err == nil {
+		t.Fatal("Expected error for unknown level")
+	}
+}
+
+func Test_Level_String(t *testing.T) {
+	if SeverityWarning.String() != LevelWarning {
+		t.Fatalf("Expected [%s], received [%s]", LevelWarning, SeverityWarning.String())
+	}
+
+	if Level(100).String() != "unknown" {
+		t.Fatalf("Expected [unknown], received [%s]", Level(100).String())
+	}
+
+	if !SeverityError.AtLeast(SeverityWarning) || SeverityError.Below(SeverityWarning) {
+		t.Fatal("Expected error to be at least warning")
+	}
+}
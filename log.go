@@ -23,11 +23,84 @@ const (
 
 // Log type
 type Log struct {
-	ID      string
-	Level   string
-	Message string
-	Context string
-	Time    *time.Time
+	ID       string     `json:"id"`
+	Level    string     `json:"level"`
+	Severity Level      `json:"severity"`
+	Message  string     `json:"message"`
+	Context  string     `json:"context,omitempty"`
+	Time     *time.Time `json:"time"`
+
+	// SourceFile, SourceLine and SourceFunction are where the log was
+	// added from, set when caller capture is enabled
+	SourceFile     string `db:"source_file" json:"source_file,omitempty"`
+	SourceLine     int    `db:"source_line" json:"source_line,omitempty"`
+	SourceFunction string `db:"source_function" json:"source_function,omitempty"`
+
+	// Service, Host, Environment and Version identify where the log comes
+	// from, missing values are set from the store defaults
+	Service     string `db:"service" json:"service,omitempty"`
+	Host        string `db:"host" json:"host,omitempty"`
+	Environment string `db:"environment" json:"environment,omitempty"`
+	Version     string `db:"version" json:"version,omitempty"`
+
+	// RequestID, TraceID, SpanID and UserID correlate the log with
+	// a request, a trace and a user
+	RequestID string `db:"request_id" json:"request_id,omitempty"`
+	TraceID   string `db:"trace_id" json:"trace_id,omitempty"`
+	SpanID    string `db:"span_id" json:"span_id,omitempty"`
+	UserID    string `db:"user_id" json:"user_id,omitempty"`
+}
+
+// LogOption changes a log before it is stored
+type LogOption func(logEntry *Log)
+
+// WithTime sets the time of the log, i.e. when the event happened
+// rather than when it is stored
+func WithTime(t time.Time) LogOption {
+	return func(logEntry *Log) {
+		logEntry.Time = &t
+	}
+}
+
+// WithSource sets where the log was added from, i.e. when
+// logging on behalf of another function
+func WithSource(file string, line int, function string) LogOption {
+	return func(logEntry *Log) {
+		logEntry.SourceFile = file
+		logEntry.SourceLine = line
+		logEntry.SourceFunction = function
+	}
+}
+
+// WithRequestID sets the request ID of the log
+func WithRequestID(requestID string) LogOption {
+	return func(logEntry *Log) {
+		logEntry.RequestID = requestID
+	}
+}
+
+// WithTrace sets the trace and span IDs of the log
+func WithTrace(traceID string, spanID string) LogOption {
+	return func(logEntry *Log) {
+		logEntry.TraceID = traceID
+		logEntry.SpanID = spanID
+	}
+}
+
+// WithUserID sets the user ID of the log
+func WithUserID(userID string) LogOption {
+	return func(logEntry *Log) {
+		logEntry.UserID = userID
+	}
+}
+
+// applyLogOptions applies the options to the log
+func applyLogOptions(logEntry *Log, opts []LogOption) {
+	for _, opt := range opts {
+		if opt != nil {
+			opt(logEntry)
+		}
+	}
 }
 
 // BeforeCreate adds UID to model
@@ -0,0 +1,151 @@
+package logstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reflect"
+
+	"github.com/gouniverse/sb"
+)
+
+// Maximum number of bound parameters in a single statement per dialect
+const (
+	maxParamsMssql    = 2100
+	maxParamsMysql    = 65535
+	maxParamsPostgres = 65535
+	maxParamsSqlite   = 999
+)
+
+// maxRowsMssql is the maximum number of rows in a SQL Server VALUES clause
+const maxRowsMssql = 1000
+
+// LogBatch adds many logs using multi-row inserts inside one transaction.
+// The logs are written synchronously, even when asynchronous writing is enabled.
+// Logs below the minimum level are dropped.
+func (st *storeImplementation) LogBatch(logEntries []*Log) error {
+	enabledEntries := make([]*Log, 0, len(logEntries))
+
+	for _, logEntry := range logEntries {
+		if logEntry == nil {
+			return errors.New("log store: log entry is nil")
+		}
+
+		if !st.Enabled(logEntry.Level) {
+			continue
+		}
+
+		st.prepareLog(logEntry)
+		enabledEntries = append(enabledEntries, logEntry)
+	}
+
+	return st.insertLogs(enabledEntries)
+}
+
+// insertLogs writes the logs in chunks of multi-row inserts inside one transaction
+func (st *storeImplementation) insertLogs(logEntries []*Log) error {
+	if len(logEntries) < 1 {
+		return nil
+	}
+
+	tx, err := st.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	chunkSize := st.batchChunkSize()
+
+	for start := 0; start < len(logEntries); start += chunkSize {
+		end := min(start+chunkSize, len(logEntries))
+
+		sqlStr, sqlParams, err := st.dialect().
+			Insert(st.logTableName).
+			Rows(logEntries[start:end]).
+			Prepared(true).
+			ToSQL()
+
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		if _, err := tx.Exec(sqlStr, sqlParams...); err != nil {
+			if st.debugEnabled {
+				log.Println(err.Error())
+			}
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertQueuedLogs writes a batch of the asynchronous writer, inserting
+// the logs one at a time when the batch fails, so a log rejected by the
+// database does not lose the other logs of the batch
+func (st *storeImplementation) insertQueuedLogs(logEntries []*Log) error {
+	err := st.insertLogs(logEntries)
+
+	if err == nil || len(logEntries) < 2 {
+		return err
+	}
+
+	errs := []error{}
+
+	for _, logEntry := range logEntries {
+		if err := st.insertLog(context.Background(), logEntry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// batchChunkSize returns the number of rows per insert statement,
+// keeping the bound parameters within the limit of the database
+func (st *storeImplementation) batchChunkSize() int {
+	// SQL Server reserves parameters for the call itself, stay strictly below the limit
+	chunkSize := (st.maxParams() - 1) / logInsertColumnCount()
+
+	if st.dbDriverName == sb.DIALECT_MSSQL && chunkSize > maxRowsMssql {
+		chunkSize = maxRowsMssql
+	}
+
+	return max(chunkSize, 1)
+}
+
+// maxParams returns the maximum number of bound parameters in a single
+// statement of the database, the SQLite limit for unknown databases
+func (st *storeImplementation) maxParams() int {
+	switch st.dbDriverName {
+	case sb.DIALECT_MSSQL:
+		return maxParamsMssql
+	case sb.DIALECT_MYSQL:
+		return maxParamsMysql
+	case sb.DIALECT_POSTGRES:
+		return maxParamsPostgres
+	}
+
+	return maxParamsSqlite
+}
+
+// logInsertColumnCount returns the number of columns inserted per log,
+// which are the fields of Log not excluded with a `db:"-"` tag
+func logInsertColumnCount() int {
+	logType := reflect.TypeOf(Log{})
+	count := 0
+
+	for i := 0; i < logType.NumField(); i++ {
+		if logType.Field(i).Tag.Get("db") != "-" {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,234 @@
+package logstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/gouniverse/sb"
+)
+
+// LogCursorDefaultLimit is the page size used when the query has no limit
+const LogCursorDefaultLimit = 100
+
+const (
+	cursorDirectionNext     = "next"
+	cursorDirectionPrevious = "previous"
+)
+
+// LogPage is a page of logs returned by LogListByCursor
+type LogPage struct {
+	// Logs in the order requested by the query (newest first by default)
+	Logs []Log
+
+	// NextCursor points after the last log of the page, empty if there are no more logs
+	NextCursor string
+
+	// PreviousCursor points before the first log of the page, empty on the first page
+	PreviousCursor string
+}
+
+// logCursor is the decoded content of an opaque cursor token
+type logCursor struct {
+	Time      time.Time `json:"t"`
+	ID        string    `json:"i"`
+	Direction string    `json:"d"`
+}
+
+// encodeLogCursor returns an opaque cursor token positioned at the log
+func encodeLogCursor(logEntry Log, direction string) string {
+	cursor := logCursor{
+		ID:        logEntry.ID,
+		Direction: direction,
+	}
+
+	if logEntry.Time != nil {
+		cursor.Time = logEntry.Time.UTC()
+	}
+
+	cursorBytes, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(cursorBytes)
+}
+
+// decodeLogCursor parses an opaque cursor token
+func decodeLogCursor(token string) (*logCursor, error) {
+	cursorBytes, err := base64.RawURLEncoding.DecodeString(token)
+
+	if err != nil {
+		return nil, errors.New("log store: invalid cursor")
+	}
+
+	cursor := &logCursor{}
+
+	if err := json.Unmarshal(cursorBytes, cursor); err != nil {
+		return nil, errors.New("log store: invalid cursor")
+	}
+
+	if cursor.ID == "" || (cursor.Direction != cursorDirectionNext && cursor.Direction != cursorDirectionPrevious) {
+		return nil, errors.New("log store: invalid cursor")
+	}
+
+	return cursor, nil
+}
+
+// LogListByCursor returns a page of logs matching the query, ordered by
+// time and ID, starting at the cursor. An empty cursor returns the first page.
+//
+// Unlike offset pagination, pages are positioned by the (time, id) of the
+// boundary log, so they stay stable while new logs are being inserted.
+// The query limit is the page size, order by and offset are not supported.
+func (st *storeImplementation) LogListByCursor(query LogQueryInterface, cursor string) (LogPage, error) {
+	page := LogPage{Logs: []Log{}}
+
+	if query == nil {
+		query = LogQuery()
+	}
+
+	if err := st.validateLogQuery(context.Background(), query); err != nil {
+		return page, err
+	}
+
+	if query.HasOrderBy() && query.OrderBy() != COLUMN_TIME {
+		return page, errors.New("log store: cursor pagination is ordered by time, order_by is not supported")
+	}
+
+	if query.HasOffset() {
+		return page, errors.New("log store: cursor pagination does not support offset")
+	}
+
+	limit := LogCursorDefaultLimit
+	if query.HasLimit() && query.Limit() > 0 {
+		limit = query.Limit()
+	}
+
+	sortDirection := sb.DESC
+	if query.HasSortDirection() {
+		sortDirection = query.SortDirection()
+	}
+
+	var position *logCursor
+	if cursor != "" {
+		decoded, err := decodeLogCursor(cursor)
+		if err != nil {
+			return page, err
+		}
+		position = decoded
+	}
+
+	isPrevious := position != nil && position.Direction == cursorDirectionPrevious
+
+	// Walking backwards reads the rows in the opposite order
+	// and reverses them afterwards
+	readDirection := sortDirection
+	if isPrevious && sortDirection == sb.ASC {
+		readDirection = sb.DESC
+	} else if isPrevious {
+		readDirection = sb.ASC
+	}
+
+	q := st.logSelectQuery(query).
+		ClearOrder().
+		ClearLimit().
+		Order(logOrderExpression(COLUMN_TIME, readDirection), logOrderExpression(COLUMN_ID, readDirection)).
+		Limit(uint(limit + 1))
+
+	if position != nil {
+		q = q.Where(logCursorCondition(position, readDirection))
+	}
+
+	sqlStr, sqlParams, err := q.Select(logSelectColumns()...).Prepared(true).ToSQL()
+
+	if err != nil {
+		return page, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := st.db.Query(sqlStr, sqlParams...)
+
+	if err != nil {
+		if st.debugEnabled {
+			log.Println(err.Error())
+		}
+		return page, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		logEntry, err := scanLog(rows)
+
+		if err != nil {
+			return page, err
+		}
+
+		page.Logs = append(page.Logs, *logEntry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+
+	hasMore := len(page.Logs) > limit
+	if hasMore {
+		page.Logs = page.Logs[:limit]
+	}
+
+	if isPrevious {
+		slices.Reverse(page.Logs)
+	}
+
+	if len(page.Logs) < 1 {
+		return page, nil
+	}
+
+	first := page.Logs[0]
+	last := page.Logs[len(page.Logs)-1]
+
+	if isPrevious {
+		if hasMore {
+			page.PreviousCursor = encodeLogCursor(first, cursorDirectionPrevious)
+		}
+		page.NextCursor = encodeLogCursor(last, cursorDirectionNext)
+	} else {
+		if position != nil {
+			page.PreviousCursor = encodeLogCursor(first, cursorDirectionPrevious)
+		}
+		if hasMore {
+			page.NextCursor = encodeLogCursor(last, cursorDirectionNext)
+		}
+	}
+
+	return page, nil
+}
+
+// logCursorCondition selects the rows strictly after the cursor
+// position when reading in the given direction
+func logCursorCondition(position *logCursor, readDirection string) exp.Expression {
+	if readDirection == sb.ASC {
+		return goqu.Or(
+			goqu.C(COLUMN_TIME).Gt(position.Time),
+			goqu.And(
+				goqu.C(COLUMN_TIME).Eq(position.Time),
+				goqu.C(COLUMN_ID).Gt(position.ID),
+			),
+		)
+	}
+
+	return goqu.Or(
+		goqu.C(COLUMN_TIME).Lt(position.Time),
+		goqu.And(
+			goqu.C(COLUMN_TIME).Eq(position.Time),
+			goqu.C(COLUMN_ID).Lt(position.ID),
+		),
+	)
+}
@@ -0,0 +1,603 @@
+package logstore
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gouniverse/sb"
+)
+
+// LogQueryInterface defines a composable query for listing logs
+type LogQueryInterface interface {
+	// Validate checks the query for invalid combinations of parameters
+	Validate() error
+
+	HasID() bool
+	ID() string
+	SetID(id string) LogQueryInterface
+
+	HasIDIn() bool
+	IDIn() []string
+	SetIDIn(ids []string) LogQueryInterface
+
+	HasLevel() bool
+	Level() string
+	SetLevel(level string) LogQueryInterface
+
+	HasLevelIn() bool
+	LevelIn() []string
+	SetLevelIn(levels []string) LogQueryInterface
+
+	// SeverityGte matches levels as severe as or more severe than the level,
+	// i.e. SetSeverityGte(SeverityWarning) for warnings and above
+	HasSeverityGte() bool
+	SeverityGte() Level
+	SetSeverityGte(severity Level) LogQueryInterface
+
+	HasSeverityLte() bool
+	SeverityLte() Level
+	SetSeverityLte(severity Level) LogQueryInterface
+
+	// SeverityIn matches the levels of the severities, whatever name or
+	// alias they were logged under, i.e. "WARN" for SeverityWarning
+	HasSeverityIn() bool
+	SeverityIn() []Level
+	SetSeverityIn(severities []Level) LogQueryInterface
+
+	HasMessageContains() bool
+	MessageContains() string
+	SetMessageContains(text string) LogQueryInterface
+
+	// ContextPathEquals matches logs whose JSON context has the value
+	// at the dotted path, i.e. SetContextPathEquals("request.id", "req-1")
+	HasContextPathEquals() bool
+	ContextPathEquals() (path string, value string)
+	SetContextPathEquals(path string, value string) LogQueryInterface
+
+	HasSourceFile() bool
+	SourceFile() string
+	SetSourceFile(file string) LogQueryInterface
+
+	HasSourceFileContains() bool
+	SourceFileContains() string
+	SetSourceFileContains(text string) LogQueryInterface
+
+	HasService() bool
+	Service() string
+	SetService(service string) LogQueryInterface
+
+	HasHost() bool
+	Host() string
+	SetHost(host string) LogQueryInterface
+
+	HasEnvironment() bool
+	Environment() string
+	SetEnvironment(environment string) LogQueryInterface
+
+	HasVersion() bool
+	Version() string
+	SetVersion(version string) LogQueryInterface
+
+	HasRequestID() bool
+	RequestID() string
+	SetRequestID(requestID string) LogQueryInterface
+
+	HasTraceID() bool
+	TraceID() string
+	SetTraceID(traceID string) LogQueryInterface
+
+	HasSpanID() bool
+	SpanID() string
+	SetSpanID(spanID string) LogQueryInterface
+
+	HasUserID() bool
+	UserID() string
+	SetUserID(userID string) LogQueryInterface
+
+	HasTimeGte() bool
+	TimeGte() time.Time
+	SetTimeGte(t time.Time) LogQueryInterface
+
+	HasTimeLte() bool
+	TimeLte() time.Time
+	SetTimeLte(t time.Time) LogQueryInterface
+
+	HasLimit() bool
+	Limit() int
+	SetLimit(limit int) LogQueryInterface
+
+	HasOffset() bool
+	Offset() int
+	SetOffset(offset int) LogQueryInterface
+
+	HasOrderBy() bool
+	OrderBy() string
+	SetOrderBy(orderBy string) LogQueryInterface
+
+	HasSortDirection() bool
+	SortDirection() string
+	SetSortDirection(sortDirection string) LogQueryInterface
+}
+
+// LogQuery creates a new empty log query
+func LogQuery() LogQueryInterface {
+	return &logQueryImplementation{
+		params: map[string]any{},
+	}
+}
+
+type logQueryImplementation struct {
+	params map[string]any
+}
+
+var _ LogQueryInterface = (*logQueryImplementation)(nil)
+
+// logQueryOrderableColumns are the columns a log query can be ordered by
+var logQueryOrderableColumns = []string{
+	COLUMN_ID,
+	COLUMN_LEVEL,
+	COLUMN_MESSAGE,
+	COLUMN_SEVERITY,
+	COLUMN_TIME,
+}
+
+// contextPathPattern matches the dotted context paths a query can filter on,
+// each key valid unquoted in the JSON paths of MySQL and SQL Server
+var contextPathPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// Validate checks the query for invalid combinations of parameters
+func (q *logQueryImplementation) Validate() error {
+	if q.HasID() && q.ID() == "" {
+		return errors.New("log query: id cannot be empty")
+	}
+
+	if q.HasIDIn() && len(q.IDIn()) < 1 {
+		return errors.New("log query: id_in cannot be empty")
+	}
+
+	if q.HasLevel() && q.Level() == "" {
+		return errors.New("log query: level cannot be empty")
+	}
+
+	if q.HasLevelIn() && len(q.LevelIn()) < 1 {
+		return errors.New("log query: level_in cannot be empty")
+	}
+
+	if q.HasSeverityIn() && len(q.SeverityIn()) < 1 {
+		return errors.New("log query: severity_in cannot be empty")
+	}
+
+	if q.HasSeverityGte() && q.HasSeverityLte() && q.SeverityGte() > q.SeverityLte() {
+		return errors.New("log query: severity_gte cannot be greater than severity_lte")
+	}
+
+	if q.HasTimeGte() && q.HasTimeLte() && q.TimeGte().After(q.TimeLte()) {
+		return errors.New("log query: time_gte cannot be after time_lte")
+	}
+
+	if q.HasContextPathEquals() {
+		path, _ := q.ContextPathEquals()
+		if !contextPathPattern.MatchString(path) {
+			return errors.New("log query: context_path must be dot separated keys of letters, digits and _, not starting with a digit")
+		}
+	}
+
+	if q.HasSourceFile() && q.SourceFile() == "" {
+		return errors.New("log query: source_file cannot be empty")
+	}
+
+	if q.HasService() && q.Service() == "" {
+		return errors.New("log query: service cannot be empty")
+	}
+
+	if q.HasHost() && q.Host() == "" {
+		return errors.New("log query: host cannot be empty")
+	}
+
+	if q.HasEnvironment() && q.Environment() == "" {
+		return errors.New("log query: environment cannot be empty")
+	}
+
+	if q.HasVersion() && q.Version() == "" {
+		return errors.New("log query: version cannot be empty")
+	}
+
+	if q.HasRequestID() && q.RequestID() == "" {
+		return errors.New("log query: request_id cannot be empty")
+	}
+
+	if q.HasTraceID() && q.TraceID() == "" {
+		return errors.New("log query: trace_id cannot be empty")
+	}
+
+	if q.HasSpanID() && q.SpanID() == "" {
+		return errors.New("log query: span_id cannot be empty")
+	}
+
+	if q.HasUserID() && q.UserID() == "" {
+		return errors.New("log query: user_id cannot be empty")
+	}
+
+	if q.HasLimit() && q.Limit() < 0 {
+		return errors.New("log query: limit cannot be negative")
+	}
+
+	if q.HasOffset() && q.Offset() < 0 {
+		return errors.New("log query: offset cannot be negative")
+	}
+
+	if q.HasOrderBy() && !slices.Contains(logQueryOrderableColumns, q.OrderBy()) {
+		return errors.New("log query: order_by must be one of " + strings.Join(logQueryOrderableColumns, ", "))
+	}
+
+	if q.HasSortDirection() && q.SortDirection() != sb.ASC && q.SortDirection() != sb.DESC {
+		return errors.New("log query: sort_direction must be asc or desc")
+	}
+
+	return nil
+}
+
+func (q *logQueryImplementation) HasID() bool {
+	return q.hasProperty("id")
+}
+
+func (q *logQueryImplementation) ID() string {
+	return q.stringProperty("id")
+}
+
+func (q *logQueryImplementation) SetID(id string) LogQueryInterface {
+	q.params["id"] = id
+	return q
+}
+
+func (q *logQueryImplementation) HasIDIn() bool {
+	return q.hasProperty("id_in")
+}
+
+func (q *logQueryImplementation) IDIn() []string {
+	return q.stringSliceProperty("id_in")
+}
+
+func (q *logQueryImplementation) SetIDIn(ids []string) LogQueryInterface {
+	q.params["id_in"] = ids
+	return q
+}
+
+func (q *logQueryImplementation) HasLevel() bool {
+	return q.hasProperty("level")
+}
+
+func (q *logQueryImplementation) Level() string {
+	return q.stringProperty("level")
+}
+
+func (q *logQueryImplementation) SetLevel(level string) LogQueryInterface {
+	q.params["level"] = level
+	return q
+}
+
+func (q *logQueryImplementation) HasLevelIn() bool {
+	return q.hasProperty("level_in")
+}
+
+func (q *logQueryImplementation) LevelIn() []string {
+	return q.stringSliceProperty("level_in")
+}
+
+func (q *logQueryImplementation) SetLevelIn(levels []string) LogQueryInterface {
+	q.params["level_in"] = levels
+	return q
+}
+
+func (q *logQueryImplementation) HasSeverityGte() bool {
+	return q.hasProperty("severity_gte")
+}
+
+func (q *logQueryImplementation) SeverityGte() Level {
+	return Level(q.intProperty("severity_gte"))
+}
+
+func (q *logQueryImplementation) SetSeverityGte(severity Level) LogQueryInterface {
+	q.params["severity_gte"] = int(severity)
+	return q
+}
+
+func (q *logQueryImplementation) HasSeverityLte() bool {
+	return q.hasProperty("severity_lte")
+}
+
+func (q *logQueryImplementation) SeverityLte() Level {
+	return Level(q.intProperty("severity_lte"))
+}
+
+func (q *logQueryImplementation) SetSeverityLte(severity Level) LogQueryInterface {
+	q.params["severity_lte"] = int(severity)
+	return q
+}
+
+func (q *logQueryImplementation) HasSeverityIn() bool {
+	return q.hasProperty("severity_in")
+}
+
+func (q *logQueryImplementation) SeverityIn() []Level {
+	if value, ok := q.params["severity_in"].([]Level); ok {
+		return value
+	}
+	return []Level{}
+}
+
+func (q *logQueryImplementation) SetSeverityIn(severities []Level) LogQueryInterface {
+	q.params["severity_in"] = severities
+	return q
+}
+
+func (q *logQueryImplementation) HasMessageContains() bool {
+	return q.hasProperty("message_contains")
+}
+
+func (q *logQueryImplementation) MessageContains() string {
+	return q.stringProperty("message_contains")
+}
+
+func (q *logQueryImplementation) SetMessageContains(text string) LogQueryInterface {
+	q.params["message_contains"] = text
+	return q
+}
+
+func (q *logQueryImplementation) HasContextPathEquals() bool {
+	return q.hasProperty("context_path") && q.hasProperty("context_path_value")
+}
+
+func (q *logQueryImplementation) ContextPathEquals() (string, string) {
+	return q.stringProperty("context_path"), q.stringProperty("context_path_value")
+}
+
+func (q *logQueryImplementation) SetContextPathEquals(path string, value string) LogQueryInterface {
+	q.params["context_path"] = path
+	q.params["context_path_value"] = value
+	return q
+}
+
+func (q *logQueryImplementation) HasSourceFile() bool {
+	return q.hasProperty("source_file")
+}
+
+func (q *logQueryImplementation) SourceFile() string {
+	return q.stringProperty("source_file")
+}
+
+func (q *logQueryImplementation) SetSourceFile(file string) LogQueryInterface {
+	q.params["source_file"] = file
+	return q
+}
+
+func (q *logQueryImplementation) HasSourceFileContains() bool {
+	return q.hasProperty("source_file_contains")
+}
+
+func (q *logQueryImplementation) SourceFileContains() string {
+	return q.stringProperty("source_file_contains")
+}
+
+func (q *logQueryImplementation) SetSourceFileContains(text string) LogQueryInterface {
+	q.params["source_file_contains"] = text
+	return q
+}
+
+func (q *logQueryImplementation) HasService() bool {
+	return q.hasProperty("service")
+}
+
+func (q *logQueryImplementation) Service() string {
+	return q.stringProperty("service")
+}
+
+func (q *logQueryImplementation) SetService(service string) LogQueryInterface {
+	q.params["service"] = service
+	return q
+}
+
+func (q *logQueryImplementation) HasHost() bool {
+	return q.hasProperty("host")
+}
+
+func (q *logQueryImplementation) Host() string {
+	return q.stringProperty("host")
+}
+
+func (q *logQueryImplementation) SetHost(host string) LogQueryInterface {
+	q.params["host"] = host
+	return q
+}
+
+func (q *logQueryImplementation) HasEnvironment() bool {
+	return q.hasProperty("environment")
+}
+
+func (q *logQueryImplementation) Environment() string {
+	return q.stringProperty("environment")
+}
+
+func (q *logQueryImplementation) SetEnvironment(environment string) LogQueryInterface {
+	q.params["environment"] = environment
+	return q
+}
+
+func (q *logQueryImplementation) HasVersion() bool {
+	return q.hasProperty("version")
+}
+
+func (q *logQueryImplementation) Version() string {
+	return q.stringProperty("version")
+}
+
+func (q *logQueryImplementation) SetVersion(version string) LogQueryInterface {
+	q.params["version"] = version
+	return q
+}
+
+func (q *logQueryImplementation) HasRequestID() bool {
+	return q.hasProperty("request_id")
+}
+
+func (q *logQueryImplementation) RequestID() string {
+	return q.stringProperty("request_id")
+}
+
+func (q *logQueryImplementation) SetRequestID(requestID string) LogQueryInterface {
+	q.params["request_id"] = requestID
+	return q
+}
+
+func (q *logQueryImplementation) HasTraceID() bool {
+	return q.hasProperty("trace_id")
+}
+
+func (q *logQueryImplementation) TraceID() string {
+	return q.stringProperty("trace_id")
+}
+
+func (q *logQueryImplementation) SetTraceID(traceID string) LogQueryInterface {
+	q.params["trace_id"] = traceID
+	return q
+}
+
+func (q *logQueryImplementation) HasSpanID() bool {
+	return q.hasProperty("span_id")
+}
+
+func (q *logQueryImplementation) SpanID() string {
+	return q.stringProperty("span_id")
+}
+
+func (q *logQueryImplementation) SetSpanID(spanID string) LogQueryInterface {
+	q.params["span_id"] = spanID
+	return q
+}
+
+func (q *logQueryImplementation) HasUserID() bool {
+	return q.hasProperty("user_id")
+}
+
+func (q *logQueryImplementation) UserID() string {
+	return q.stringProperty("user_id")
+}
+
+func (q *logQueryImplementation) SetUserID(userID string) LogQueryInterface {
+	q.params["user_id"] = userID
+	return q
+}
+
+func (q *logQueryImplementation) HasTimeGte() bool {
+	return q.hasProperty("time_gte")
+}
+
+func (q *logQueryImplementation) TimeGte() time.Time {
+	return q.timeProperty("time_gte")
+}
+
+func (q *logQueryImplementation) SetTimeGte(t time.Time) LogQueryInterface {
+	q.params["time_gte"] = t
+	return q
+}
+
+func (q *logQueryImplementation) HasTimeLte() bool {
+	return q.hasProperty("time_lte")
+}
+
+func (q *logQueryImplementation) TimeLte() time.Time {
+	return q.timeProperty("time_lte")
+}
+
+func (q *logQueryImplementation) SetTimeLte(t time.Time) LogQueryInterface {
+	q.params["time_lte"] = t
+	return q
+}
+
+func (q *logQueryImplementation) HasLimit() bool {
+	return q.hasProperty("limit")
+}
+
+func (q *logQueryImplementation) Limit() int {
+	return q.intProperty("limit")
+}
+
+func (q *logQueryImplementation) SetLimit(limit int) LogQueryInterface {
+	q.params["limit"] = limit
+	return q
+}
+
+func (q *logQueryImplementation) HasOffset() bool {
+	return q.hasProperty("offset")
+}
+
+func (q *logQueryImplementation) Offset() int {
+	return q.intProperty("offset")
+}
+
+func (q *logQueryImplementation) SetOffset(offset int) LogQueryInterface {
+	q.params["offset"] = offset
+	return q
+}
+
+func (q *logQueryImplementation) HasOrderBy() bool {
+	return q.hasProperty("order_by")
+}
+
+func (q *logQueryImplementation) OrderBy() string {
+	return q.stringProperty("order_by")
+}
+
+func (q *logQueryImplementation) SetOrderBy(orderBy string) LogQueryInterface {
+	q.params["order_by"] = orderBy
+	return q
+}
+
+func (q *logQueryImplementation) HasSortDirection() bool {
+	return q.hasProperty("sort_direction")
+}
+
+func (q *logQueryImplementation) SortDirection() string {
+	return q.stringProperty("sort_direction")
+}
+
+func (q *logQueryImplementation) SetSortDirection(sortDirection string) LogQueryInterface {
+	q.params["sort_direction"] = strings.ToLower(sortDirection)
+	return q
+}
+
+func (q *logQueryImplementation) hasProperty(key string) bool {
+	_, ok := q.params[key]
+	return ok
+}
+
+func (q *logQueryImplementation) stringProperty(key string) string {
+	if value, ok := q.params[key].(string); ok {
+		return value
+	}
+	return ""
+}
+
+func (q *logQueryImplementation) stringSliceProperty(key string) []string {
+	if value, ok := q.params[key].([]string); ok {
+		return value
+	}
+	return []string{}
+}
+
+func (q *logQueryImplementation) intProperty(key string) int {
+	if value, ok := q.params[key].(int); ok {
+		return value
+	}
+	return 0
+}
+
+func (q *logQueryImplementation) timeProperty(key string) time.Time {
+	if value, ok := q.params[key].(time.Time); ok {
+		return value
+	}
+	return time.Time{}
+}
@@ -0,0 +1,357 @@
+package logstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/gouniverse/sb"
+)
+
+// logSelectColumns returns the columns selected when reading logs
+func logSelectColumns() []any {
+	return []any{
+		COLUMN_ID,
+		COLUMN_LEVEL,
+		COLUMN_SEVERITY,
+		COLUMN_MESSAGE,
+		COLUMN_CONTEXT,
+		COLUMN_TIME,
+		COLUMN_SOURCE_FILE,
+		COLUMN_SOURCE_LINE,
+		COLUMN_SOURCE_FUNCTION,
+		COLUMN_SERVICE,
+		COLUMN_HOST,
+		COLUMN_ENVIRONMENT,
+		COLUMN_VERSION,
+		COLUMN_REQUEST_ID,
+		COLUMN_TRACE_ID,
+		COLUMN_SPAN_ID,
+		COLUMN_USER_ID,
+	}
+}
+
+// logSelectQuery builds the select dataset for the given query
+func (st *storeImplementation) logSelectQuery(query LogQueryInterface) *goqu.SelectDataset {
+	q := st.dialect().From(st.logTableName)
+
+	if query.HasID() {
+		q = q.Where(goqu.C(COLUMN_ID).Eq(query.ID()))
+	}
+
+	if query.HasIDIn() {
+		q = q.Where(goqu.C(COLUMN_ID).In(query.IDIn()))
+	}
+
+	if query.HasLevel() {
+		q = q.Where(goqu.C(COLUMN_LEVEL).Eq(query.Level()))
+	}
+
+	if query.HasLevelIn() {
+		q = q.Where(goqu.C(COLUMN_LEVEL).In(query.LevelIn()))
+	}
+
+	if query.HasSeverityGte() {
+		q = q.Where(goqu.C(COLUMN_SEVERITY).Gte(int(query.SeverityGte())))
+	}
+
+	if query.HasSeverityLte() {
+		q = q.Where(goqu.C(COLUMN_SEVERITY).Lte(int(query.SeverityLte())))
+	}
+
+	if query.HasSeverityIn() {
+		severities := make([]int, 0, len(query.SeverityIn()))
+		for _, severity := range query.SeverityIn() {
+			severities = append(severities, int(severity))
+		}
+		q = q.Where(goqu.C(COLUMN_SEVERITY).In(severities))
+	}
+
+	if query.HasMessageContains() {
+		q = q.Where(st.likeContainsExpression(COLUMN_MESSAGE, query.MessageContains()))
+	}
+
+	if query.HasContextPathEquals() {
+		path, value := query.ContextPathEquals()
+		q = q.Where(st.contextPathExpression(path).Eq(value))
+	}
+
+	if query.HasSourceFile() {
+		q = q.Where(goqu.C(COLUMN_SOURCE_FILE).Eq(query.SourceFile()))
+	}
+
+	if query.HasSourceFileContains() {
+		q = q.Where(st.likeContainsExpression(COLUMN_SOURCE_FILE, query.SourceFileContains()))
+	}
+
+	if query.HasService() {
+		q = q.Where(goqu.C(COLUMN_SERVICE).Eq(query.Service()))
+	}
+
+	if query.HasHost() {
+		q = q.Where(goqu.C(COLUMN_HOST).Eq(query.Host()))
+	}
+
+	if query.HasEnvironment() {
+		q = q.Where(goqu.C(COLUMN_ENVIRONMENT).Eq(query.Environment()))
+	}
+
+	if query.HasVersion() {
+		q = q.Where(goqu.C(COLUMN_VERSION).Eq(query.Version()))
+	}
+
+	if query.HasRequestID() {
+		q = q.Where(goqu.C(COLUMN_REQUEST_ID).Eq(query.RequestID()))
+	}
+
+	if query.HasTraceID() {
+		q = q.Where(goqu.C(COLUMN_TRACE_ID).Eq(query.TraceID()))
+	}
+
+	if query.HasSpanID() {
+		q = q.Where(goqu.C(COLUMN_SPAN_ID).Eq(query.SpanID()))
+	}
+
+	if query.HasUserID() {
+		q = q.Where(goqu.C(COLUMN_USER_ID).Eq(query.UserID()))
+	}
+
+	if query.HasTimeGte() {
+		q = q.Where(goqu.C(COLUMN_TIME).Gte(query.TimeGte().UTC()))
+	}
+
+	if query.HasTimeLte() {
+		q = q.Where(goqu.C(COLUMN_TIME).Lte(query.TimeLte().UTC()))
+	}
+
+	orderBy := COLUMN_TIME
+	if query.HasOrderBy() {
+		orderBy = query.OrderBy()
+	}
+
+	sortDirection := sb.DESC
+	if query.HasSortDirection() {
+		sortDirection = query.SortDirection()
+	}
+
+	q = q.Order(logOrderExpression(orderBy, sortDirection))
+
+	// Ties are broken by ID so that paging through equal values is stable
+	if orderBy != COLUMN_ID {
+		q = q.OrderAppend(logOrderExpression(COLUMN_ID, sortDirection))
+	}
+
+	if query.HasLimit() {
+		q = q.Limit(uint(query.Limit()))
+	}
+
+	if query.HasOffset() {
+		q = q.Offset(uint(query.Offset()))
+	}
+
+	return q
+}
+
+// likeEscapeCharacter escapes the wildcards of LIKE patterns
+const likeEscapeCharacter = `\`
+
+// likeContainsExpression matches the column values containing the text,
+// the % and _ wildcards in the text are matched literally
+func (st *storeImplementation) likeContainsExpression(column string, text string) exp.LiteralExpression {
+	escaped := []string{likeEscapeCharacter, likeEscapeCharacter + likeEscapeCharacter, "%", likeEscapeCharacter + "%", "_", likeEscapeCharacter + "_"}
+
+	// SQL Server additionally treats [ as the start of a character range
+	if st.dbDriverName == sb.DIALECT_MSSQL {
+		escaped = append(escaped, "[", likeEscapeCharacter+"[")
+	}
+
+	pattern := "%" + strings.NewReplacer(escaped...).Replace(text) + "%"
+
+	return goqu.L("? LIKE ? ESCAPE ?", goqu.C(column), pattern, likeEscapeCharacter)
+}
+
+// postgresContextPathVersion is the first PostgreSQL version, 16, with
+// pg_input_is_valid, which context path filters need
+const postgresContextPathVersion = 160000
+
+// validateLogQuery validates the query and checks the database supports it
+func (st *storeImplementation) validateLogQuery(ctx context.Context, query LogQueryInterface) error {
+	if err := query.Validate(); err != nil {
+		return err
+	}
+
+	if !query.HasContextPathEquals() || st.dbDriverName != sb.DIALECT_POSTGRES {
+		return nil
+	}
+
+	version, err := st.postgresServerVersion(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if version < postgresContextPathVersion {
+		return errors.New("log store: context path filters need PostgreSQL 16 or later, the server version is " + strconv.FormatInt(version, 10))
+	}
+
+	return nil
+}
+
+// postgresServerVersion returns the version number of the PostgreSQL
+// server, i.e. 160002 for 16.2, querying it once
+func (st *storeImplementation) postgresServerVersion(ctx context.Context) (int64, error) {
+	if version := st.postgresVersion.Load(); version > 0 {
+		return version, nil
+	}
+
+	var versionText string
+
+	if err := st.db.QueryRowContext(ctx, "SHOW server_version_num").Scan(&versionText); err != nil {
+		return 0, err
+	}
+
+	version, err := strconv.ParseInt(strings.TrimSpace(versionText), 10, 64)
+
+	if err != nil {
+		return 0, err
+	}
+
+	st.postgresVersion.Store(version)
+
+	return version, nil
+}
+
+// contextPathExpression returns the text value at the dotted path of the
+// JSON context, NULL for contexts that are not JSON objects
+func (st *storeImplementation) contextPathExpression(path string) exp.LiteralExpression {
+	column := goqu.C(COLUMN_CONTEXT)
+	jsonPath := "$." + path
+
+	switch st.dbDriverName {
+	case sb.DIALECT_MYSQL:
+		return goqu.L("CASE WHEN JSON_VALID(?) THEN JSON_UNQUOTE(JSON_EXTRACT(?, ?)) END", column, column, jsonPath)
+	case sb.DIALECT_POSTGRES:
+		// the context is cast only if it is valid JSON, as the text column
+		// can hold contexts that are not, validateLogQuery checks the server
+		// has pg_input_is_valid
+		return goqu.L("CASE WHEN pg_input_is_valid(?, 'jsonb') THEN (?::jsonb #>> ?::text[]) END", column, column, "{"+strings.ReplaceAll(path, ".", ",")+"}")
+	case sb.DIALECT_MSSQL:
+		return goqu.L("CASE WHEN ISJSON(?) = 1 THEN JSON_VALUE(?, ?) END", column, column, jsonPath)
+	}
+
+	// json_extract returns numbers as numbers, which never equal text in SQLite
+	return goqu.L("CASE WHEN json_valid(?) THEN CAST(json_extract(?, ?) AS TEXT) END", column, column, jsonPath)
+}
+
+// logOrderExpression returns an ordered expression for the column
+func logOrderExpression(column string, sortDirection string) exp.OrderedExpression {
+	if sortDirection == sb.ASC {
+		return goqu.C(column).Asc()
+	}
+	return goqu.C(column).Desc()
+}
+
+// scanLog reads the current row into a log, columns as in logSelectColumns
+func scanLog(rows *sql.Rows) (*Log, error) {
+	var id, level, message string
+	var severity, sourceLine sql.NullInt64
+	var context, sourceFile, sourceFunction sql.NullString
+	var service, host, environment, version sql.NullString
+	var requestID, traceID, spanID, userID sql.NullString
+	var logTime any
+
+	err := rows.Scan(
+		&id, &level, &severity, &message, &context, &logTime,
+		&sourceFile, &sourceLine, &sourceFunction,
+		&service, &host, &environment, &version,
+		&requestID, &traceID, &spanID, &userID,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parsedTime, err := parseLogTime(logTime)
+
+	if err != nil {
+		return nil, err
+	}
+
+	logEntry := &Log{
+		ID:       id,
+		Level:    level,
+		Severity: Level(severity.Int64),
+		Message:  message,
+		Context:  context.String,
+		Time:     parsedTime,
+
+		SourceFile:     sourceFile.String,
+		SourceLine:     int(sourceLine.Int64),
+		SourceFunction: sourceFunction.String,
+
+		Service:     service.String,
+		Host:        host.String,
+		Environment: environment.String,
+		Version:     version.String,
+
+		RequestID: requestID.String,
+		TraceID:   traceID.String,
+		SpanID:    spanID.String,
+		UserID:    userID.String,
+	}
+
+	return logEntry, nil
+}
+
+// logTimeLayouts are the textual time layouts returned by the supported
+// drivers when the time column is not scanned as time.Time, i.e. SQLite
+// without a declared DATETIME type or MySQL without parseTime=true
+var logTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// parseLogTime converts a scanned time column value into a time,
+// textual values without a zone are interpreted as UTC
+func parseLogTime(value any) (*time.Time, error) {
+	var text string
+
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case time.Time:
+		return &v, nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return nil, fmt.Errorf("log store: unsupported time value of type %T", value)
+	}
+
+	text = strings.TrimSpace(text)
+
+	if text == "" {
+		return nil, nil
+	}
+
+	for _, layout := range logTimeLayouts {
+		t, err := time.ParseInLocation(layout, text, time.UTC)
+		if err == nil {
+			return &t, nil
+		}
+	}
+
+	return nil, errors.New("log store: cannot parse time value " + text)
+}
@@ -0,0 +1,508 @@
+package logstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/gouniverse/sb"
+)
+
+// Columns of the schema migrations table
+const (
+	COLUMN_MIGRATION_VERSION     = "version"
+	COLUMN_MIGRATION_DESCRIPTION = "description"
+	COLUMN_MIGRATION_APPLIED_AT  = "applied_at"
+)
+
+// Migration describes a schema migration
+type Migration struct {
+	Version     int
+	Description string
+}
+
+// MigrationStatus reports the schema version of the log table
+type MigrationStatus struct {
+	// CurrentVersion is the version of the last applied migration,
+	// 0 if no migration was applied
+	CurrentVersion int
+
+	// LatestVersion is the version of the last known migration
+	LatestVersion int
+
+	// Pending are the migrations not applied yet, in order
+	Pending []Migration
+}
+
+// IsUpToDate returns whether all migrations are applied
+func (status MigrationStatus) IsUpToDate() bool {
+	return status.CurrentVersion >= status.LatestVersion
+}
+
+// logMigration is a schema migration, up returns the SQL statements
+// migrating the schema from the previous version for the store's dialect
+type logMigration struct {
+	version     int
+	description string
+	up          func(st *storeImplementation) ([]string, error)
+}
+
+// logMigrations returns the migrations in order. Applied migrations
+// are never changed, schema changes are added as new migrations.
+func logMigrations() []logMigration {
+	return []logMigration{
+		{
+			version:     1,
+			description: "create log table",
+			up:          (*storeImplementation).migrationCreateTable,
+		},
+		{
+			version:     2,
+			description: "add severity column",
+			up:          (*storeImplementation).migrationAddSeverity,
+		},
+		{
+			version:     3,
+			description: "add source columns",
+			up: func(st *storeImplementation) ([]string, error) {
+				return st.sqlAddMissingColumns(sourceColumns())
+			},
+		},
+		{
+			version:     4,
+			description: "add service and correlation columns",
+			up: func(st *storeImplementation) ([]string, error) {
+				return st.sqlAddMissingColumns(correlationColumns())
+			},
+		},
+		{
+			version:     5,
+			description: "store fractional seconds of the time column",
+			up:          (*storeImplementation).migrationTimePrecision,
+		},
+		{
+			version:     6,
+			description: "widen the message column",
+			up:          (*storeImplementation).migrationWidenMessage,
+		},
+	}
+}
+
+// migrationCreateTable creates the log table as first released,
+// later columns are added by the following migrations. On SQL Server
+// the context is NVARCHAR(MAX), as the builder has no long text type.
+func (st *storeImplementation) migrationCreateTable() ([]string, error) {
+	builder := sb.NewBuilder(st.dbDriverName).
+		Table(st.logTableName).
+		Column(sb.Column{
+			Name:       COLUMN_ID,
+			Type:       sb.COLUMN_TYPE_STRING,
+			Length:     40,
+			PrimaryKey: true,
+		}).
+		Column(sb.Column{
+			Name:   COLUMN_LEVEL,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: 40,
+		}).
+		Column(sb.Column{
+			Name:   COLUMN_MESSAGE,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: 510,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_CONTEXT,
+			Type: st.longTextColumnType(),
+		}).
+		Column(sb.Column{
+			Name: COLUMN_TIME,
+			Type: sb.COLUMN_TYPE_DATETIME,
+		})
+
+	return []string{st.sqlCreateIfNotExists(builder, st.logTableName)}, nil
+}
+
+// migrationAddSeverity adds the severity column and fills it in from the level column
+func (st *storeImplementation) migrationAddSeverity() ([]string, error) {
+	if st.columnExists(COLUMN_SEVERITY) {
+		return []string{}, nil
+	}
+
+	sqlStr, err := sb.NewBuilder(st.dbDriverName).TableColumnAdd(st.logTableName, sb.Column{
+		Name:     COLUMN_SEVERITY,
+		Type:     sb.COLUMN_TYPE_INTEGER,
+		Nullable: true,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	statements := []string{sqlStr}
+
+	for severity := SeverityTrace; severity <= SeverityPanic; severity++ {
+		// levels logged under an alias, i.e. "WARN", get their severity too
+		aliases := []string{}
+		for alias, aliasSeverity := range levelAliases {
+			if aliasSeverity == severity {
+				aliases = append(aliases, alias)
+			}
+		}
+		slices.Sort(aliases)
+
+		sqlStr, _, err := st.dialect().
+			Update(st.logTableName).
+			Set(goqu.Record{COLUMN_SEVERITY: int(severity)}).
+			Where(goqu.Func("LOWER", goqu.C(COLUMN_LEVEL)).In(aliases)).
+			ToSQL()
+
+		if err != nil {
+			return nil, err
+		}
+
+		statements = append(statements, sqlStr)
+	}
+
+	return statements, nil
+}
+
+// sqlAddMissingColumns returns the statements adding the columns the log table does not have
+func (st *storeImplementation) sqlAddMissingColumns(columns []sb.Column) ([]string, error) {
+	statements := []string{}
+
+	for _, column := range columns {
+		if st.columnExists(column.Name) {
+			continue
+		}
+
+		sqlStr, err := sb.NewBuilder(st.dbDriverName).TableColumnAdd(st.logTableName, column)
+
+		if err != nil {
+			return nil, err
+		}
+
+		statements = append(statements, sqlStr)
+	}
+
+	return statements, nil
+}
+
+// migrationTimePrecision keeps the microseconds of the time on MySQL,
+// where DATETIME drops them, the other databases keep them already
+func (st *storeImplementation) migrationTimePrecision() ([]string, error) {
+	if st.dbDriverName != sb.DIALECT_MYSQL {
+		return []string{}, nil
+	}
+
+	return []string{
+		"ALTER TABLE `" + st.logTableName + "` MODIFY `" + COLUMN_TIME + "` DATETIME(6) NOT NULL;",
+	}, nil
+}
+
+// migrationWidenMessage removes the 510 characters limit of the message,
+// on PostgreSQL and SQLite the column is TEXT already
+func (st *storeImplementation) migrationWidenMessage() ([]string, error) {
+	switch st.dbDriverName {
+	case sb.DIALECT_MYSQL:
+		return []string{
+			"ALTER TABLE `" + st.logTableName + "` MODIFY `" + COLUMN_MESSAGE + "` LONGTEXT NOT NULL;",
+		}, nil
+	case sb.DIALECT_MSSQL:
+		return []string{
+			"ALTER TABLE [" + st.logTableName + "] ALTER COLUMN [" + COLUMN_MESSAGE + "] NVARCHAR(MAX) NOT NULL;",
+		}, nil
+	}
+
+	return []string{}, nil
+}
+
+// migrationTableName returns the name of the table recording the applied migrations
+func (st *storeImplementation) migrationTableName() string {
+	return st.logTableName + "_migrations"
+}
+
+// sqlCreateMigrationTable returns the SQL creating the migrations table
+func (st *storeImplementation) sqlCreateMigrationTable() string {
+	builder := sb.NewBuilder(st.dbDriverName).
+		Table(st.migrationTableName()).
+		Column(sb.Column{
+			Name:       COLUMN_MIGRATION_VERSION,
+			Type:       sb.COLUMN_TYPE_INTEGER,
+			PrimaryKey: true,
+		}).
+		Column(sb.Column{
+			Name:   COLUMN_MIGRATION_DESCRIPTION,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: 255,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_MIGRATION_APPLIED_AT,
+			Type: sb.COLUMN_TYPE_DATETIME,
+		})
+
+	return st.sqlCreateIfNotExists(builder, st.migrationTableName())
+}
+
+// sqlRecordMigration returns the SQL recording the migration as applied
+func (st *storeImplementation) sqlRecordMigration(migration logMigration) (string, error) {
+	sqlStr, _, err := st.dialect().
+		Insert(st.migrationTableName()).
+		Rows(goqu.Record{
+			COLUMN_MIGRATION_VERSION:     migration.version,
+			COLUMN_MIGRATION_DESCRIPTION: migration.description,
+			COLUMN_MIGRATION_APPLIED_AT:  time.Now().UTC(),
+		}).
+		ToSQL()
+
+	return sqlStr, err
+}
+
+// schemaVersion returns the version of the last applied migration,
+// 0 if the migrations table does not exist yet
+func (st *storeImplementation) schemaVersion() (int, error) {
+	if !st.tableColumnExists(st.migrationTableName(), COLUMN_MIGRATION_VERSION) {
+		return 0, nil
+	}
+
+	sqlStr, _, err := st.dialect().
+		From(st.migrationTableName()).
+		Select(goqu.MAX(COLUMN_MIGRATION_VERSION)).
+		ToSQL()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	var version sql.NullInt64
+
+	if err := st.db.QueryRow(sqlStr).Scan(&version); err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// MigrationStatus reports the current and the latest schema version
+func (st *storeImplementation) MigrationStatus() (MigrationStatus, error) {
+	migrations := logMigrations()
+
+	current, err := st.schemaVersion()
+
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	status := MigrationStatus{
+		CurrentVersion: current,
+		LatestVersion:  migrations[len(migrations)-1].version,
+		Pending:        []Migration{},
+	}
+
+	for _, migration := range migrations {
+		if migration.version > current {
+			status.Pending = append(status.Pending, Migration{
+				Version:     migration.version,
+				Description: migration.description,
+			})
+		}
+	}
+
+	return status, nil
+}
+
+// MigrateDryRun returns the SQL statements AutoMigrate would execute,
+// without executing them. Like executed statements, they are logged
+// only when debug is enabled.
+func (st *storeImplementation) MigrateDryRun() ([]string, error) {
+	return st.migrate(true)
+}
+
+// migrationLockTimeout is how many seconds a migration waits for the
+// migrations of another process on MySQL
+const migrationLockTimeout = 60
+
+// sqlExecutor executes a statement, on the database or in a transaction
+type sqlExecutor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// migrate applies the pending migrations in order, recording each as
+// applied once its statements succeed, then creates the missing indexes.
+// In dry run mode the statements are returned without being executed.
+//
+// Each migration runs in a transaction with the insert of its version,
+// so processes migrating at the same time apply it once: the others fail,
+// roll back and find the migration applied. MySQL commits DDL statements
+// implicitly, there the migrations hold an advisory lock instead.
+func (st *storeImplementation) migrate(dryRun bool) ([]string, error) {
+	executed := []string{}
+
+	exec := func(executor sqlExecutor, sqlStr string) error {
+		if sqlStr == "" {
+			return errors.New("log store: migration SQL is not supported for driver " + st.dbDriverName)
+		}
+
+		executed = append(executed, sqlStr)
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		if dryRun {
+			return nil
+		}
+
+		_, err := executor.Exec(sqlStr)
+		return err
+	}
+
+	if !dryRun {
+		unlock, err := st.lockMigrations()
+
+		if err != nil {
+			return executed, err
+		}
+
+		defer unlock()
+	}
+
+	current, err := st.schemaVersion()
+
+	if err != nil {
+		return executed, err
+	}
+
+	if current == 0 {
+		err := exec(st.db, st.sqlCreateMigrationTable())
+
+		// another process may have created the table in the meantime
+		if err != nil && !st.tableColumnExists(st.migrationTableName(), COLUMN_MIGRATION_VERSION) {
+			return executed, err
+		}
+	}
+
+	applyMigration := func(migration logMigration) error {
+		statements, err := migration.up(st)
+
+		if err != nil {
+			return err
+		}
+
+		sqlStr, err := st.sqlRecordMigration(migration)
+
+		if err != nil {
+			return err
+		}
+
+		statements = append(statements, sqlStr)
+
+		if dryRun || st.dbDriverName == sb.DIALECT_MYSQL {
+			for _, sqlStr := range statements {
+				if err := exec(st.db, sqlStr); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		tx, err := st.db.Begin()
+
+		if err != nil {
+			return err
+		}
+
+		for _, sqlStr := range statements {
+			if err := exec(tx, sqlStr); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		return tx.Commit()
+	}
+
+	for _, migration := range logMigrations() {
+		if migration.version <= current {
+			continue
+		}
+
+		if err := applyMigration(migration); err != nil {
+			// the migration may have been applied by another process,
+			// whose transaction failed ours
+			if version, versionErr := st.schemaVersion(); versionErr == nil && version >= migration.version {
+				continue
+			}
+
+			return executed, errors.New("log store: migration " + strconv.Itoa(migration.version) + " failed: " + err.Error())
+		}
+	}
+
+	// the indexes are not versioned, so they are created once enabled
+	if !st.indexesDisabled {
+		statements, err := st.sqlCreateMissingIndexes()
+
+		if err != nil {
+			return executed, err
+		}
+
+		for _, sqlStr := range statements {
+			if err := exec(st.db, sqlStr); err != nil {
+				// the index may have been created by another process
+				if missing, missingErr := st.sqlCreateMissingIndexes(); missingErr == nil && !slices.Contains(missing, sqlStr) {
+					continue
+				}
+
+				return executed, err
+			}
+		}
+	}
+
+	return executed, nil
+}
+
+// lockMigrations takes the MySQL advisory lock of the migrations, as
+// MySQL cannot roll back DDL statements. The other databases run each
+// migration in a transaction, for them the returned unlock does nothing.
+func (st *storeImplementation) lockMigrations() (func(), error) {
+	if st.dbDriverName != sb.DIALECT_MYSQL {
+		return func() {}, nil
+	}
+
+	// the lock belongs to the connection, which is kept until unlocked
+	conn, err := st.db.Conn(context.Background())
+
+	if err != nil {
+		return nil, err
+	}
+
+	var acquired sql.NullInt64
+
+	err = conn.QueryRowContext(context.Background(), "SELECT GET_LOCK(?, ?)", st.migrationTableName(), migrationLockTimeout).Scan(&acquired)
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if acquired.Int64 != 1 {
+		conn.Close()
+		return nil, errors.New("log store: timed out waiting for the migrations of another process")
+	}
+
+	unlock := func() {
+		conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", st.migrationTableName())
+		conn.Close()
+	}
+
+	return unlock, nil
+}
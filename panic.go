@@ -0,0 +1,87 @@
+package logstore
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// Context keys of the panic details
+const (
+	ContextKeyPanic     = "panic"
+	ContextKeyPanicType = "panic_type"
+	ContextKeyStack     = "stack"
+)
+
+// withStack adds the stack trace of the current goroutine to the log context
+func withStack() LogOption {
+	stack := string(debug.Stack())
+
+	return func(logEntry *Log) {
+		addContextValues(logEntry, map[string]any{
+			ContextKeyStack: stack,
+		})
+	}
+}
+
+// flushBeforePanic writes the queued logs, so the panic log is not lost
+// when the panic is not recovered
+func (st *storeImplementation) flushBeforePanic() {
+	ctx, cancel := context.WithTimeout(context.Background(), FatalFlushTimeout)
+	defer cancel()
+
+	st.Flush(ctx)
+}
+
+// Recover logs a recovered panic with its stack trace, stopping the panic.
+// It must be deferred directly:
+//
+//	defer logStore.Recover(ctx)
+func (st *storeImplementation) Recover(ctx context.Context) {
+	if recovered := recover(); recovered != nil {
+		st.logRecovered(ctx, recovered)
+	}
+}
+
+// RecoverAndRepanic logs a recovered panic with its stack trace, writes
+// any queued logs and panics again with the same value. It must be
+// deferred directly:
+//
+//	defer logStore.RecoverAndRepanic(ctx)
+func (st *storeImplementation) RecoverAndRepanic(ctx context.Context) {
+	recovered := recover()
+
+	if recovered == nil {
+		return
+	}
+
+	st.logRecovered(ctx, recovered)
+	st.flushBeforePanic()
+
+	panic(recovered)
+}
+
+// logRecovered stores a panic level log for the recovered value
+func (st *storeImplementation) logRecovered(ctx context.Context, recovered any) {
+	panicValue := any(fmt.Sprint(recovered))
+	if err, ok := recovered.(error); ok {
+		panicValue = encodeError(err)
+	}
+
+	logEntry := Log{
+		Level:   LevelPanic,
+		Message: fmt.Sprintf("panic: %v", recovered),
+	}
+
+	addContextValues(&logEntry, map[string]any{
+		ContextKeyPanic:     panicValue,
+		ContextKeyPanicType: fmt.Sprintf("%T", recovered),
+		ContextKeyStack:     string(debug.Stack()),
+	})
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	st.LogContext(ctx, &logEntry)
+}
@@ -0,0 +1,359 @@
+package logstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/sb"
+)
+
+// DefaultRetentionChunkSize is the number of logs deleted per statement when
+// purging, capped at the bound parameter limit of the database
+const DefaultRetentionChunkSize = 1000
+
+// RetentionPolicy defines which logs are expired and purged
+type RetentionPolicy struct {
+	// MaxAge is how long logs are kept, 0 keeps them regardless of age
+	MaxAge time.Duration
+
+	// MaxRows is the number of most recent logs kept, 0 keeps any number
+	MaxRows int64
+
+	// LevelMaxAge overrides MaxAge per level, i.e.
+	// {SeverityDebug: 3 * 24 * time.Hour, SeverityError: 90 * 24 * time.Hour},
+	// logs are matched by their severity, so a "WARN" log is a warning
+	LevelMaxAge map[Level]time.Duration
+}
+
+// IsEmpty returns whether the policy keeps all logs
+func (policy RetentionPolicy) IsEmpty() bool {
+	return policy.MaxAge <= 0 && policy.MaxRows <= 0 && len(policy.LevelMaxAge) < 1
+}
+
+// Validate checks the policy for negative limits and unknown levels
+func (policy RetentionPolicy) Validate() error {
+	if policy.MaxAge < 0 {
+		return errors.New("log store: retention max age cannot be negative")
+	}
+
+	if policy.MaxRows < 0 {
+		return errors.New("log store: retention max rows cannot be negative")
+	}
+
+	for level, maxAge := range policy.LevelMaxAge {
+		if !level.IsValid() {
+			return errors.New("log store: retention level " + strconv.Itoa(int(level)) + " is unknown")
+		}
+
+		if maxAge <= 0 {
+			return errors.New("log store: retention max age of level " + level.String() + " must be positive")
+		}
+	}
+
+	return nil
+}
+
+// PurgeExpired deletes the logs expired by the retention policy, in chunks
+// so no statement holds long locks. It returns the number of deleted logs.
+func (st *storeImplementation) PurgeExpired(ctx context.Context) (int64, error) {
+	policy := st.retention
+
+	if policy.IsEmpty() {
+		return 0, nil
+	}
+
+	now := carbon.Now(carbon.UTC).StdTime()
+	deleted := int64(0)
+
+	// the levels are purged in a stable order, so a failure is reproducible
+	severities := make([]Level, 0, len(policy.LevelMaxAge))
+	for severity := range policy.LevelMaxAge {
+		severities = append(severities, severity)
+	}
+	slices.Sort(severities)
+
+	for _, severity := range severities {
+		count, err := st.purgeWhere(ctx, goqu.And(
+			goqu.C(COLUMN_SEVERITY).Eq(int(severity)),
+			goqu.C(COLUMN_TIME).Lt(now.Add(-policy.LevelMaxAge[severity])),
+		))
+
+		deleted += count
+
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	if policy.MaxAge > 0 {
+		conditions := []exp.Expression{goqu.C(COLUMN_TIME).Lt(now.Add(-policy.MaxAge))}
+
+		// logs of unknown levels have no severity and expire with MaxAge
+		if len(severities) > 0 {
+			values := make([]int, 0, len(severities))
+			for _, severity := range severities {
+				values = append(values, int(severity))
+			}
+
+			conditions = append(conditions, goqu.Or(
+				goqu.C(COLUMN_SEVERITY).NotIn(values),
+				goqu.C(COLUMN_SEVERITY).IsNull(),
+			))
+		}
+
+		count, err := st.purgeWhere(ctx, goqu.And(conditions...))
+
+		deleted += count
+
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	if policy.MaxRows > 0 {
+		count, err := st.purgeOverMaxRows(ctx, policy.MaxRows)
+
+		deleted += count
+
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// purgeOverMaxRows deletes the logs older than the most recent maxRows logs.
+// The last kept log is found by paging through the time and ID of the most
+// recent logs, as a deep offset is scanned row by row and cannot be cancelled.
+func (st *storeImplementation) purgeOverMaxRows(ctx context.Context, maxRows int64) (int64, error) {
+	var lastKept *logCursor
+
+	for remaining := maxRows; remaining > 0; {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		limit := min(remaining, int64(st.retentionChunkSize))
+
+		positions, err := st.newestLogPositions(ctx, lastKept, limit)
+
+		if err != nil {
+			return 0, err
+		}
+
+		// there are no more logs than kept
+		if int64(len(positions)) < limit {
+			return 0, nil
+		}
+
+		lastKept = positions[len(positions)-1]
+		remaining -= limit
+	}
+
+	// everything after the last kept log in the time and ID order is deleted
+	return st.purgeWhere(ctx, logCursorCondition(lastKept, sb.DESC))
+}
+
+// newestLogPositions returns the time and ID of the next logs after the
+// position, newest first, or of the newest logs if the position is nil
+func (st *storeImplementation) newestLogPositions(ctx context.Context, after *logCursor, limit int64) ([]*logCursor, error) {
+	q := st.dialect().
+		From(st.logTableName).
+		Select(COLUMN_TIME, COLUMN_ID).
+		Order(goqu.C(COLUMN_TIME).Desc(), goqu.C(COLUMN_ID).Desc()).
+		Limit(uint(limit))
+
+	if after != nil {
+		q = q.Where(logCursorCondition(after, sb.DESC))
+	}
+
+	sqlStr, sqlParams, err := q.Prepared(true).ToSQL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := st.db.QueryContext(ctx, sqlStr, sqlParams...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	positions := []*logCursor{}
+
+	for rows.Next() {
+		var id string
+		var logTime any
+
+		if err := rows.Scan(&logTime, &id); err != nil {
+			return nil, err
+		}
+
+		parsedTime, err := parseLogTime(logTime)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if parsedTime == nil {
+			return nil, errors.New("log store: log " + id + " has no time")
+		}
+
+		positions = append(positions, &logCursor{Time: parsedTime.UTC(), ID: id})
+	}
+
+	return positions, rows.Err()
+}
+
+// purgeWhere deletes the logs matching the condition in chunks of the
+// retention chunk size, selecting the IDs of each chunk first
+func (st *storeImplementation) purgeWhere(ctx context.Context, condition exp.Expression) (int64, error) {
+	deleted := int64(0)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+
+		ids, err := st.expiredIDs(ctx, condition)
+
+		if err != nil {
+			return deleted, err
+		}
+
+		if len(ids) < 1 {
+			return deleted, nil
+		}
+
+		count, err := st.deleteByIDs(ctx, ids)
+
+		deleted += count
+
+		if err != nil {
+			return deleted, err
+		}
+
+		if len(ids) < st.retentionChunkSize {
+			return deleted, nil
+		}
+	}
+}
+
+// expiredIDs returns the IDs of the next chunk of logs matching the condition, oldest first
+func (st *storeImplementation) expiredIDs(ctx context.Context, condition exp.Expression) ([]string, error) {
+	sqlStr, sqlParams, err := st.dialect().
+		From(st.logTableName).
+		Select(COLUMN_ID).
+		Where(condition).
+		Order(goqu.C(COLUMN_TIME).Asc(), goqu.C(COLUMN_ID).Asc()).
+		Limit(uint(st.retentionChunkSize)).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := st.db.QueryContext(ctx, sqlStr, sqlParams...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := []string{}
+
+	for rows.Next() {
+		var id string
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// retentionJanitor purges the expired logs periodically from a background goroutine
+type retentionJanitor struct {
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// startRetentionJanitor starts purging the expired logs every interval
+func (st *storeImplementation) startRetentionJanitor(interval time.Duration) *retentionJanitor {
+	janitor := &retentionJanitor{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(janitor.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithCancel(context.Background())
+
+				// a purge in progress is cancelled when the janitor is stopped
+				go func() {
+					select {
+					case <-janitor.stop:
+						cancel()
+					case <-ctx.Done():
+					}
+				}()
+
+				if _, err := st.PurgeExpired(ctx); err != nil && !errors.Is(err, context.Canceled) {
+					log.Println("log store: purge failed:", err)
+				}
+
+				cancel()
+			case <-janitor.stop:
+				return
+			}
+		}
+	}()
+
+	return janitor
+}
+
+// close stops the janitor and waits until a purge in progress returns
+func (janitor *retentionJanitor) close(ctx context.Context) error {
+	janitor.stopOnce.Do(func() {
+		close(janitor.stop)
+	})
+
+	select {
+	case <-janitor.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
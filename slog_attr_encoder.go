@@ -0,0 +1,130 @@
+package logstore
+
+import (
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// encodeAttrValue converts a resolved, non group, slog value into a value
+// that marshals to predictable JSON:
+//   - durations as their string form, i.e. "1.5s"
+//   - times as RFC 3339 strings with nanoseconds
+//   - errors as an object with the message and the chain of wrapped errors
+//   - byte slices as a string if valid UTF-8, base64 encoded otherwise
+//   - NaN and infinite floats as strings
+func encodeAttrValue(value slog.Value) any {
+	switch value.Kind() {
+	case slog.KindDuration:
+		return value.Duration().String()
+	case slog.KindTime:
+		return value.Time().Format(time.RFC3339Nano)
+	case slog.KindFloat64:
+		return encodeFloat(value.Float64())
+	case slog.KindAny:
+		return encodeAny(value.Any())
+	}
+
+	return value.Any()
+}
+
+// encodeAny converts the values of kind any, that do not marshal well to JSON
+func encodeAny(value any) any {
+	switch v := value.(type) {
+	case error:
+		return encodeError(v)
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v)
+		}
+		return base64.StdEncoding.EncodeToString(v)
+	case float32:
+		return encodeFloat(float64(v))
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	}
+
+	return value
+}
+
+// encodeFloat returns NaN and infinities, which JSON cannot represent, as strings
+func encodeFloat(f float64) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return slog.Float64Value(f).String()
+	}
+	return f
+}
+
+// encodeError returns the error message, and the messages of the wrapped
+// errors under "chain" if the error wraps others
+func encodeError(err error) map[string]any {
+	encoded := map[string]any{
+		"message": err.Error(),
+	}
+
+	chain := []string{}
+	for _, wrapped := range unwrapErrors(err) {
+		chain = append(chain, wrapped.Error())
+	}
+
+	if len(chain) > 0 {
+		encoded["chain"] = chain
+	}
+
+	return encoded
+}
+
+// unwrapErrors returns all errors wrapped by the error, depth first,
+// following both errors.Unwrap and errors.Join
+func unwrapErrors(err error) []error {
+	var wrapped []error
+
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		wrapped = e.Unwrap()
+	default:
+		if inner := errors.Unwrap(err); inner != nil {
+			wrapped = []error{inner}
+		}
+	}
+
+	result := []error{}
+
+	for _, inner := range wrapped {
+		if inner == nil {
+			continue
+		}
+		result = append(result, inner)
+		result = append(result, unwrapErrors(inner)...)
+	}
+
+	return result
+}
+
+// flattenGroups returns the attributes with nested groups flattened into
+// keys joined by dots, i.e. {"request":{"id":1}} into {"request.id":1}
+func flattenGroups(attrs attrGroup) attrGroup {
+	flat := attrGroup{}
+	flattenGroupsInto(flat, "", attrs)
+	return flat
+}
+
+func flattenGroupsInto(flat attrGroup, prefix string, attrs attrGroup) {
+	for key, value := range attrs {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if group, ok := value.(attrGroup); ok {
+			flattenGroupsInto(flat, key, group)
+			continue
+		}
+
+		flat[key] = value
+	}
+}
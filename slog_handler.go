@@ -1,103 +1,329 @@
 package logstore
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"log"
 	"log/slog"
 	"os"
-	"sync"
+	"runtime"
 )
 
 var _ slog.Handler = (*SlogHandler)(nil) // verify it extends the slog interface
 
+// Custom slog levels commonly used next to the standard ones
+const (
+	SlogLevelTrace    = slog.Level(-8)
+	SlogLevelNotice   = slog.Level(2)
+	SlogLevelCritical = slog.Level(12)
+)
+
+// SlogLevelKey is the context key the numeric slog level of a record is stored under
+const SlogLevelKey = "slog_level"
+
 type SlogHandler struct {
-	slogHandler slog.Handler
-	buffer      *bytes.Buffer
-	mutex       *sync.Mutex
-	logStore    StoreInterface
+	logStore StoreInterface
+	options  SlogHandlerOptions
+
+	// groupOrAttrs are the groups and attributes added with WithGroup and
+	// WithAttrs, in the order they were added
+	groupOrAttrs []groupOrAttrs
 }
 
+// SlogHandlerOptions are the options of a SlogHandler,
+// mirroring slog.HandlerOptions
+type SlogHandlerOptions struct {
+	// Level is the minimum level stored, defaults to slog.LevelInfo
+	Level slog.Leveler
+
+	// AddSource adds the file, line and function of the log call to the
+	// context under the "source" key, the source columns are set regardless
+	AddSource bool
+
+	// FlattenGroups stores the attributes of groups with dotted keys,
+	// i.e. "request.id", instead of nested objects
+	FlattenGroups bool
+
+	// ReplaceAttr is called on each attribute before it is stored,
+	// as in slog.HandlerOptions. Returning an empty attribute drops it.
+	ReplaceAttr func(groups []string, attr slog.Attr) slog.Attr
+
+	// LevelMapping maps custom slog levels onto store levels, i.e.
+	// SlogLevelNotice to LevelInfo. Levels not in the mapping are mapped by
+	// range: below debug is trace, [debug, info) is debug, [info, warn) is
+	// info, [warn, error) is warning, [error, critical) is error and
+	// critical and above is fatal.
+	LevelMapping map[slog.Level]string
+
+	// Console is an optional secondary handler the records are also
+	// sent to, i.e. a slog.TextHandler writing to the console
+	Console slog.Handler
+}
+
+// attrGroup holds the attributes of a group, distinguishing
+// groups from map values of attributes
+type attrGroup map[string]any
+
+// groupOrAttrs holds either a group name or a list of attributes
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// NewSlogHandler creates a handler storing debug and above records,
+// also writing them to stdout as text
 func NewSlogHandler(logStore StoreInterface) *SlogHandler {
-	return &SlogHandler{
-		slogHandler: slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+	return NewSlogHandlerWithOptions(logStore, SlogHandlerOptions{
+		Level: slog.LevelDebug,
+		Console: slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 			Level: slog.LevelDebug,
 		}),
-		buffer:   &bytes.Buffer{},
-		mutex:    &sync.Mutex{},
+	})
+}
+
+// NewSlogHandlerWithOptions creates a handler storing the records in the log store
+func NewSlogHandlerWithOptions(logStore StoreInterface, options SlogHandlerOptions) *SlogHandler {
+	if options.Level == nil {
+		options.Level = slog.LevelInfo
+	}
+
+	return &SlogHandler{
 		logStore: logStore,
+		options:  options,
 	}
 }
 
 func (handler *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	return handler.slogHandler.Enabled(ctx, level)
+	return handler.storeEnabled(level) || handler.consoleEnabled(ctx, level)
+}
+
+// storeEnabled returns whether records of the level are stored
+func (handler *SlogHandler) storeEnabled(level slog.Level) bool {
+	return level >= handler.options.Level.Level()
+}
+
+// consoleEnabled returns whether records of the level are sent to the console handler
+func (handler *SlogHandler) consoleEnabled(ctx context.Context, level slog.Level) bool {
+	return handler.options.Console != nil && handler.options.Console.Enabled(ctx, level)
 }
 
 func (handler *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
-	level := record.Level.String()
-	message := record.Message
-	attrs, err := handler.computeAttrs(ctx, record)
+	if handler.consoleEnabled(ctx, record.Level) {
+		if err := handler.options.Console.Handle(ctx, record); err != nil {
+			return fmt.Errorf("error when calling console handler's Handle: %w", err)
+		}
+	}
+
+	if !handler.storeEnabled(record.Level) {
+		return nil
+	}
+
+	attrs := handler.computeAttrs(record)
+	attrs[SlogLevelKey] = int(record.Level)
+
+	contextBytes, err := json.Marshal(attrs)
 
 	if err != nil {
-		return fmt.Errorf("error when calling computeAttrs: %w", err)
+		log.Println(err)
+		contextBytes = []byte("JSON encode error")
 	}
 
-	if level == slog.LevelDebug.String() {
-		return handler.logStore.DebugWithContext(message, attrs)
+	logEntry := Log{
+		Level:   handler.storeLevel(record.Level),
+		Message: record.Message,
+		Context: string(contextBytes),
 	}
 
-	if level == slog.LevelInfo.String() {
-		return handler.logStore.InfoWithContext(message, attrs)
+	// the record knows the caller, so the store does not capture the
+	// frames of slog itself
+	if record.PC != 0 {
+		source := recordSource(record)
+		logEntry.SourceFile = source.File
+		logEntry.SourceLine = source.Line
+		logEntry.SourceFunction = source.Function
 	}
 
-	if level == slog.LevelWarn.String() {
-		return handler.logStore.WarnWithContext(message, attrs)
+	// the record time is kept, so buffered or replayed records are stored
+	// with the time they were logged rather than the time they were written
+	if !record.Time.IsZero() {
+		recordTime := record.Time
+		logEntry.Time = &recordTime
 	}
 
-	if level == slog.LevelError.String() {
-		return handler.logStore.ErrorWithContext(message, attrs)
+	return handler.logStore.LogContext(ctx, &logEntry)
+}
+
+// storeLevel maps the slog level onto a store level, using the level
+// mapping for exact matches and the slog level ranges otherwise
+func (handler *SlogHandler) storeLevel(level slog.Level) string {
+	if storeLevel, ok := handler.options.LevelMapping[level]; ok {
+		return storeLevel
 	}
 
-	return handler.logStore.FatalWithContext(message, attrs)
+	return slogLevelToStoreLevel(level)
+}
+
+// slogLevelToStoreLevel maps the slog level onto a store level by the slog level ranges
+func slogLevelToStoreLevel(level slog.Level) string {
+	switch {
+	case level < slog.LevelDebug:
+		return LevelTrace
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarning
+	case level < SlogLevelCritical:
+		return LevelError
+	}
+
+	return LevelFatal
 }
 
 func (handler *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return &SlogHandler{
-		slogHandler: handler.slogHandler.WithAttrs(attrs),
-		buffer:      handler.buffer,
-		mutex:       handler.mutex,
+	if len(attrs) == 0 {
+		return handler
+	}
+
+	derived := handler.withGroupOrAttrs(groupOrAttrs{attrs: attrs})
+
+	if derived.options.Console != nil {
+		derived.options.Console = derived.options.Console.WithAttrs(attrs)
 	}
+
+	return derived
 }
 
 func (handler *SlogHandler) WithGroup(name string) slog.Handler {
-	return &SlogHandler{
-		slogHandler: handler.slogHandler.WithGroup(name),
-		buffer:      handler.buffer,
-		mutex:       handler.mutex,
+	if name == "" {
+		return handler
+	}
+
+	derived := handler.withGroupOrAttrs(groupOrAttrs{group: name})
+
+	if derived.options.Console != nil {
+		derived.options.Console = derived.options.Console.WithGroup(name)
 	}
+
+	return derived
 }
 
-func (handler *SlogHandler) computeAttrs(
-	ctx context.Context,
-	r slog.Record,
-) (map[string]any, error) {
-	handler.mutex.Lock()
+// withGroupOrAttrs returns a copy of the handler, sharing the store,
+// with the group or attributes appended
+func (handler *SlogHandler) withGroupOrAttrs(goa groupOrAttrs) *SlogHandler {
+	derived := *handler
+	derived.groupOrAttrs = make([]groupOrAttrs, len(handler.groupOrAttrs)+1)
+	copy(derived.groupOrAttrs, handler.groupOrAttrs)
+	derived.groupOrAttrs[len(derived.groupOrAttrs)-1] = goa
+
+	return &derived
+}
 
-	defer func() {
-		handler.buffer.Reset()
-		handler.mutex.Unlock()
-	}()
+// computeAttrs returns the attributes of the handler and the record,
+// nested by group, to be stored as the log context
+func (handler *SlogHandler) computeAttrs(r slog.Record) attrGroup {
+	attrs := attrGroup{}
 
-	if err := handler.slogHandler.Handle(ctx, r); err != nil {
-		return nil, fmt.Errorf("error when calling inner handler's Handle: %w", err)
+	if handler.options.AddSource && r.PC != 0 {
+		handler.addAttr(attrs, nil, slog.Any(slog.SourceKey, recordSource(r)))
 	}
 
-	attrs := map[string]any{}
+	// the attributes of the record belong to the innermost group
+	current := attrs
+	groups := []string{}
+
+	for _, goa := range handler.groupOrAttrs {
+		if goa.group != "" {
+			group := attrGroup{}
+			current[goa.group] = group
+			current = group
+			groups = append(groups, goa.group)
+			continue
+		}
+
+		for _, attr := range goa.attrs {
+			handler.addAttr(current, groups, attr)
+		}
+	}
 
 	r.Attrs(func(attr slog.Attr) bool {
-		attrs[attr.Key] = attr.Value.Any()
+		handler.addAttr(current, groups, attr)
 		return true
 	})
 
-	return attrs, nil
+	removeEmptyGroups(attrs)
+
+	if handler.options.FlattenGroups {
+		return flattenGroups(attrs)
+	}
+
+	return attrs
+}
+
+// addAttr adds the attribute to the map, group attributes become nested maps
+func (handler *SlogHandler) addAttr(attrs attrGroup, groups []string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+
+	if handler.options.ReplaceAttr != nil && attr.Value.Kind() != slog.KindGroup {
+		attr = handler.options.ReplaceAttr(groups, attr)
+		attr.Value = attr.Value.Resolve()
+	}
+
+	// empty attributes are ignored, as by the standard handlers
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if attr.Value.Kind() != slog.KindGroup {
+		attrs[attr.Key] = encodeAttrValue(attr.Value)
+		return
+	}
+
+	// groups with an empty key are inlined
+	target := attrs
+	targetGroups := groups
+	if attr.Key != "" {
+		group, ok := attrs[attr.Key].(attrGroup)
+		if !ok {
+			group = attrGroup{}
+			attrs[attr.Key] = group
+		}
+		target = group
+		targetGroups = append(groups[:len(groups):len(groups)], attr.Key)
+	}
+
+	for _, groupAttr := range attr.Value.Group() {
+		handler.addAttr(target, targetGroups, groupAttr)
+	}
+}
+
+// recordSource returns the file, line and function the record was logged from
+func recordSource(r slog.Record) *slog.Source {
+	frames := runtime.CallersFrames([]uintptr{r.PC})
+	frame, _ := frames.Next()
+
+	return &slog.Source{
+		Function: frame.Function,
+		File:     frame.File,
+		Line:     frame.Line,
+	}
+}
+
+// removeEmptyGroups removes the groups without attributes, as by the standard handlers
+func removeEmptyGroups(attrs attrGroup) {
+	for key, value := range attrs {
+		group, ok := value.(attrGroup)
+
+		if !ok {
+			continue
+		}
+
+		removeEmptyGroups(group)
+
+		if len(group) == 0 {
+			delete(attrs, key)
+		}
+	}
 }
@@ -0,0 +1,321 @@
+package logstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_SlogHandler_WithAttrsAndGroups(t *testing.T) {
+	db := InitDB("test_log_store_slog_handler_with_attrs.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	logger := slog.New(NewSlogHandler(s)).
+		With("service", "api").
+		WithGroup("request").
+		With("id", "req-1").
+		WithGroup("empty")
+
+	logger.Info("Hello", slog.Group("user", "name", "John Doe"))
+
+	logs, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 {
+		t.Fatalf("Expected 1 log, received %d", len(logs))
+	}
+
+	expected := `{"request":{"empty":{"user":{"name":"John Doe"}},"id":"req-1"},"service":"api","slog_level":0}`
+	if logs[0].Context != expected {
+		t.Fatalf("Expected context [%s], received [%s]", expected, logs[0].Context)
+	}
+
+	logger.WithGroup("unused").Info("No attributes")
+
+	logs, err = s.LogList(LogQuery().SetMessageContains("No attributes"))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	expected = `{"request":{"id":"req-1"},"service":"api","slog_level":0}`
+	if len(logs) != 1 || logs[0].Context != expected {
+		t.Fatalf("Expected context [%s], received %v", expected, logs)
+	}
+}
+
+func Test_SlogHandler_Options(t *testing.T) {
+	db := InitDB("test_log_store_slog_handler_options.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	console := &bytes.Buffer{}
+
+	handler := NewSlogHandlerWithOptions(s, SlogHandlerOptions{
+		Level:     slog.LevelWarn,
+		AddSource: true,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if attr.Key == "password" {
+				return slog.String("password", "***")
+			}
+			if attr.Key == "drop" {
+				return slog.Attr{}
+			}
+			return attr
+		},
+		Console: slog.NewTextHandler(console, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	})
+
+	logger := slog.New(handler)
+	logger.Info("Not stored")
+	logger.Warn("Stored", "password", "secret", "drop", "me")
+
+	if !strings.Contains(console.String(), "Not stored") || !strings.Contains(console.String(), "Stored") {
+		t.Fatalf("Expected both records on the console, received [%s]", console.String())
+	}
+
+	logs, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 {
+		t.Fatalf("Expected 1 stored log, received %d", len(logs))
+	}
+
+	stored := map[string]any{}
+	if err := json.Unmarshal([]byte(logs[0].Context), &stored); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if stored["password"] != "***" {
+		t.Fatalf("Expected password to be replaced, received %v", stored)
+	}
+
+	if _, ok := stored["drop"]; ok {
+		t.Fatalf("Expected drop to be removed, received %v", stored)
+	}
+
+	source, ok := stored["source"].(map[string]any)
+	if !ok || !strings.HasSuffix(source["file"].(string), "slog_handler_test.go") || source["line"].(float64) == 0 {
+		t.Fatalf("Expected source of the log call, received %v", stored["source"])
+	}
+
+	if logs[0].SourceFile != source["file"] || logs[0].SourceLine != int(source["line"].(float64)) {
+		t.Fatalf("Expected the source columns to be set, received %s:%d", logs[0].SourceFile, logs[0].SourceLine)
+	}
+}
+
+func Test_SlogHandler_CallerCapture(t *testing.T) {
+	db := InitDB("test_log_store_slog_handler_caller_capture.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		CallerEnabled:      true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	slog.New(NewSlogHandlerWithOptions(s, SlogHandlerOptions{})).Info("hello")
+
+	logs, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 {
+		t.Fatalf("Expected 1 log, received %d", len(logs))
+	}
+
+	if !strings.HasSuffix(logs[0].SourceFile, "slog_handler_test.go") || !strings.HasSuffix(logs[0].SourceFunction, "Test_SlogHandler_CallerCapture") {
+		t.Fatalf("Expected the slog caller to be stored, received %s:%d %s", logs[0].SourceFile, logs[0].SourceLine, logs[0].SourceFunction)
+	}
+
+	if strings.Contains(logs[0].Context, `"source"`) {
+		t.Fatalf("Expected no source key without AddSource, received %s", logs[0].Context)
+	}
+}
+
+func Test_SlogHandler_LevelMapping(t *testing.T) {
+	db := InitDB("test_log_store_slog_handler_level_mapping.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	handler := NewSlogHandlerWithOptions(s, SlogHandlerOptions{
+		Level: SlogLevelTrace,
+		LevelMapping: map[slog.Level]string{
+			slog.LevelError + 2: LevelPanic,
+		},
+	})
+
+	cases := map[slog.Level]string{
+		SlogLevelTrace:      LevelTrace,
+		slog.LevelDebug:     LevelDebug,
+		slog.LevelDebug + 1: LevelDebug,
+		slog.LevelInfo:      LevelInfo,
+		SlogLevelNotice:     LevelInfo,
+		slog.LevelWarn:      LevelWarning,
+		slog.LevelError:     LevelError,
+		slog.LevelError + 2: LevelPanic,
+		SlogLevelCritical:   LevelFatal,
+	}
+
+	for slogLevel, expected := range cases {
+		if level := handler.storeLevel(slogLevel); level != expected {
+			t.Fatalf("Expected slog level [%v] to map to [%s], received [%s]", slogLevel, expected, level)
+		}
+	}
+
+	slog.New(handler).Log(context.Background(), SlogLevelTrace, "trace")
+
+	logs, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 || logs[0].Level != LevelTrace || logs[0].Context != `{"slog_level":-8}` {
+		t.Fatalf("Expected a trace log with the slog level, received %v", logs)
+	}
+}
+
+type testLogValuer struct {
+	name string
+}
+
+func (v testLogValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", v.name))
+}
+
+func Test_SlogHandler_AttrEncoding(t *testing.T) {
+	db := InitDB("test_log_store_slog_handler_attr_encoding.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	logger := slog.New(NewSlogHandlerWithOptions(s, SlogHandlerOptions{}))
+
+	baseErr := errors.New("connection refused")
+	wrappedErr := fmt.Errorf("query failed: %w", baseErr)
+
+	logger.Info("Encoded",
+		"user", testLogValuer{name: "John Doe"},
+		"err", wrappedErr,
+		"elapsed", 1500*time.Millisecond,
+		"at", time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		"body", []byte("hello"),
+		"binary", []byte{0xff, 0xfe},
+	)
+
+	logs, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 {
+		t.Fatalf("Expected 1 log, received %d", len(logs))
+	}
+
+	expected := `{"at":"2024-01-02T03:04:05.000000006Z","binary":"//4=","body":"hello","elapsed":"1.5s","err":{"chain":["connection refused"],"message":"query failed: connection refused"},"slog_level":0,"user":{"name":"John Doe"}}`
+	if logs[0].Context != expected {
+		t.Fatalf("Expected context [%s], received [%s]", expected, logs[0].Context)
+	}
+
+	flat := slog.New(NewSlogHandlerWithOptions(s, SlogHandlerOptions{FlattenGroups: true}))
+	flat.WithGroup("request").Warn("Flat", "id", 1, slog.Group("user", "name", "John Doe"))
+
+	logs, err = s.LogList(LogQuery().SetLevel(LevelWarning))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	expected = `{"request.id":1,"request.user.name":"John Doe","slog_level":4}`
+	if len(logs) != 1 || logs[0].Context != expected {
+		t.Fatalf("Expected context [%s], received %v", expected, logs)
+	}
+}
+
+func Test_unwrapErrors(t *testing.T) {
+	first := errors.New("first")
+	second := errors.New("second")
+	joined := fmt.Errorf("wrapped: %w", errors.Join(first, second))
+
+	chain := unwrapErrors(joined)
+
+	if len(chain) != 3 || chain[1] != first || chain[2] != second {
+		t.Fatalf("Expected the joined errors in the chain, received %v", chain)
+	}
+}
+
+func Test_SlogHandler_RecordTime(t *testing.T) {
+	db := InitDB("test_log_store_slog_handler_record_time.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	recordTime := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	record := slog.NewRecord(recordTime, slog.LevelInfo, "Replayed", 0)
+
+	err = NewSlogHandlerWithOptions(s, SlogHandlerOptions{}).Handle(context.Background(), record)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	logs, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 || logs[0].Time == nil || !logs[0].Time.Equal(recordTime) {
+		t.Fatalf("Expected the record time [%v], received %v", recordTime, logs)
+	}
+}
@@ -2,9 +2,17 @@ package logstore
 
 import "github.com/gouniverse/sb"
 
-// SqlCreateTable returns a SQL string for creating the setting table
+// SqlCreateTable returns a SQL string for creating the log table with the
+// latest schema, AutoMigrate creates and upgrades it through the migrations
 func (store *storeImplementation) SqlCreateTable() string {
-	sql := sb.NewBuilder(sb.DatabaseDriverName(store.db)).
+	// MySQL DATETIME drops the fractional seconds unless a precision is
+	// given, the other databases keep at least microseconds by default
+	timeLength := 0
+	if store.dbDriverName == sb.DIALECT_MYSQL {
+		timeLength = 6
+	}
+
+	sql := sb.NewBuilder(store.dbDriverName).
 		Table(store.logTableName).
 		Column(sb.Column{
 			Name:       COLUMN_ID,
@@ -18,19 +26,132 @@ func (store *storeImplementation) SqlCreateTable() string {
 			Length: 40,
 		}).
 		Column(sb.Column{
-			Name:   COLUMN_MESSAGE,
-			Type:   sb.COLUMN_TYPE_STRING,
-			Length: 510,
+			Name:     COLUMN_SEVERITY,
+			Type:     sb.COLUMN_TYPE_INTEGER,
+			Nullable: true,
 		}).
 		Column(sb.Column{
-			Name: COLUMN_CONTEXT,
-			Type: sb.COLUMN_TYPE_LONGTEXT,
+			Name: COLUMN_MESSAGE,
+			Type: store.longTextColumnType(),
 		}).
 		Column(sb.Column{
-			Name: COLUMN_TIME,
-			Type: sb.COLUMN_TYPE_DATETIME,
+			Name: COLUMN_CONTEXT,
+			Type: store.longTextColumnType(),
 		}).
-		CreateIfNotExists()
+		Column(sb.Column{
+			Name:   COLUMN_TIME,
+			Type:   sb.COLUMN_TYPE_DATETIME,
+			Length: timeLength,
+		})
+
+	for _, column := range sourceColumns() {
+		sql = sql.Column(column)
+	}
+
+	for _, column := range correlationColumns() {
+		sql = sql.Column(column)
+	}
+
+	return store.sqlCreateIfNotExists(sql, store.logTableName)
+}
+
+// sqlCreateIfNotExists returns the SQL creating the table of the builder
+// unless it exists. The builder has no CREATE TABLE IF NOT EXISTS for
+// SQL Server, where the table is looked up with OBJECT_ID instead.
+func (store *storeImplementation) sqlCreateIfNotExists(builder sb.BuilderInterface, table string) string {
+	if store.dbDriverName == sb.DIALECT_MSSQL {
+		return "IF OBJECT_ID(N'" + table + "', N'U') IS NULL " + builder.Create()
+	}
+
+	return builder.CreateIfNotExists()
+}
+
+// longTextColumnType returns the type of the unbounded text columns. The
+// builder has no long text type for SQL Server, where TEXT is deprecated.
+func (store *storeImplementation) longTextColumnType() string {
+	if store.dbDriverName == sb.DIALECT_MSSQL {
+		return "NVARCHAR(MAX)"
+	}
+
+	return sb.COLUMN_TYPE_LONGTEXT
+}
+
+// sourceColumns returns the columns holding where the log was added from.
+// They are nullable, as they are added to existing tables by AutoMigrate.
+func sourceColumns() []sb.Column {
+	return []sb.Column{
+		{
+			Name:     COLUMN_SOURCE_FILE,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   sourceColumnLength,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_SOURCE_LINE,
+			Type:     sb.COLUMN_TYPE_INTEGER,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_SOURCE_FUNCTION,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   sourceColumnLength,
+			Nullable: true,
+		},
+	}
+}
 
-	return sql
+// correlationColumns returns the columns identifying the origin of the log and
+// correlating it with requests, traces and users. They are nullable, as they
+// are added to existing tables by AutoMigrate.
+func correlationColumns() []sb.Column {
+	return []sb.Column{
+		{
+			Name:     COLUMN_SERVICE,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   100,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_HOST,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   255,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_ENVIRONMENT,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   40,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_VERSION,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   40,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_REQUEST_ID,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   100,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_TRACE_ID,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   64,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_SPAN_ID,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   32,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_USER_ID,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   100,
+			Nullable: true,
+		},
+	}
 }
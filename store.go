@@ -1,10 +1,14 @@
 package logstore
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"log"
+	"os"
+	"sync/atomic"
+	"time"
 
 	"github.com/doug-martin/goqu/v9"
 	_ "github.com/doug-martin/goqu/v9/dialect/mysql"
@@ -23,6 +27,38 @@ type storeImplementation struct {
 	dbDriverName       string
 	automigrateEnabled bool
 	debugEnabled       bool
+
+	// asyncWriter is set when asynchronous writing is enabled
+	asyncWriter *asyncWriter
+
+	// contextExtractors add correlation values from the context to the logs
+	contextExtractors map[string]ContextExtractor
+
+	// minLevelSeverity is the severity of the minimum level stored
+	minLevelSeverity atomic.Int32
+
+	// postgresVersion is the PostgreSQL server version, once queried
+	postgresVersion atomic.Int64
+
+	fatalBehavior FatalBehavior
+	exitFunc      func(code int)
+
+	callerEnabled bool
+	callerSkip    int
+
+	// service, host, environment and version are set on logs without them
+	service     string
+	host        string
+	environment string
+	version     string
+
+	indexesDisabled bool
+
+	retention          RetentionPolicy
+	retentionChunkSize int
+
+	// retentionJanitor is set when the expired logs are purged periodically
+	retentionJanitor *retentionJanitor
 }
 
 // NewStoreOptions define the options for creating a new session store
@@ -32,6 +68,70 @@ type NewStoreOptions struct {
 	DbDriverName       string
 	AutomigrateEnabled bool
 	DebugEnabled       bool
+
+	// AsyncEnabled queues the logs and writes them from a background
+	// goroutine, call Close before shutting down so no log is lost
+	AsyncEnabled bool
+
+	// AsyncQueueSize is the number of logs that can be queued before
+	// logging blocks, defaults to DefaultAsyncQueueSize
+	AsyncQueueSize int
+
+	// AsyncFlushInterval is how often queued logs are written,
+	// defaults to DefaultAsyncFlushInterval
+	AsyncFlushInterval time.Duration
+
+	// AsyncMaxBatchSize is the number of queued logs written at once,
+	// defaults to DefaultAsyncMaxBatchSize
+	AsyncMaxBatchSize int
+
+	// ContextExtractors add correlation values (i.e. request ID, trace ID,
+	// user ID) from the context to the logs, keyed by the name they are stored under
+	ContextExtractors map[string]ContextExtractor
+
+	// MinLevel is the minimum level stored, logs below it are dropped,
+	// including those of LogBatch and Import, defaults to LevelTrace
+	// (all logs are stored)
+	MinLevel string
+
+	// FatalBehavior defines what the Fatal methods do after logging,
+	// defaults to FatalBehaviorExit
+	FatalBehavior FatalBehavior
+
+	// ExitFunc is called with exit code 1 by the Fatal methods under
+	// FatalBehaviorExit, defaults to os.Exit. Tests can substitute a recorder.
+	ExitFunc func(code int)
+
+	// CallerEnabled stores the file, line and function the log was added
+	// from, the first caller outside of the log store
+	CallerEnabled bool
+
+	// CallerSkip is the number of additional frames skipped when capturing
+	// the caller, i.e. 1 when logging through a wrapper function
+	CallerSkip int
+
+	// Service, Host, Environment and Version are stored with every log
+	// not setting its own, i.e. "billing", os.Hostname(), "production", "v1.2.3"
+	Service     string
+	Host        string
+	Environment string
+	Version     string
+
+	// IndexesDisabled skips creating the indexes on the time, level,
+	// severity and correlation columns, for write optimised deployments
+	IndexesDisabled bool
+
+	// Retention defines which logs are expired and deleted by PurgeExpired
+	Retention RetentionPolicy
+
+	// RetentionInterval starts a background goroutine calling PurgeExpired
+	// every interval, 0 leaves purging to the application
+	RetentionInterval time.Duration
+
+	// RetentionChunkSize is the number of logs deleted per statement,
+	// defaults to DefaultRetentionChunkSize and is capped at the bound
+	// parameter limit of the database, 998 on SQLite
+	RetentionChunkSize int
 }
 
 // NewStore creates a new session store
@@ -42,6 +142,22 @@ func NewStore(opts NewStoreOptions) (*storeImplementation, error) {
 		db:                 opts.DB,
 		dbDriverName:       opts.DbDriverName,
 		debugEnabled:       opts.DebugEnabled,
+		contextExtractors:  opts.ContextExtractors,
+		fatalBehavior:      opts.FatalBehavior,
+		exitFunc:           opts.ExitFunc,
+		callerEnabled:      opts.CallerEnabled,
+		callerSkip:         opts.CallerSkip,
+		service:            opts.Service,
+		host:               opts.Host,
+		environment:        opts.Environment,
+		version:            opts.Version,
+		indexesDisabled:    opts.IndexesDisabled,
+		retention:          opts.Retention,
+		retentionChunkSize: opts.RetentionChunkSize,
+	}
+
+	if store.exitFunc == nil {
+		store.exitFunc = os.Exit
 	}
 
 	if store.logTableName == "" {
@@ -56,28 +172,145 @@ func NewStore(opts NewStoreOptions) (*storeImplementation, error) {
 		store.dbDriverName = sb.DatabaseDriverName(store.db)
 	}
 
+	if store.retentionChunkSize <= 0 {
+		store.retentionChunkSize = DefaultRetentionChunkSize
+	}
+
+	// each deleted ID is a bound parameter of the delete statement
+	store.retentionChunkSize = min(store.retentionChunkSize, store.maxParams()-1)
+
+	if opts.MinLevel != "" {
+		if err := store.SetMinLevel(opts.MinLevel); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := store.retention.Validate(); err != nil {
+		return nil, err
+	}
+
 	if store.automigrateEnabled {
 		store.AutoMigrate()
 	}
 
+	if opts.AsyncEnabled {
+		store.asyncWriter = newAsyncWriter(
+			opts.AsyncQueueSize,
+			opts.AsyncFlushInterval,
+			opts.AsyncMaxBatchSize,
+			store.insertQueuedLogs,
+		)
+	}
+
+	if opts.RetentionInterval > 0 && !store.retention.IsEmpty() {
+		store.retentionJanitor = store.startRetentionJanitor(opts.RetentionInterval)
+	}
+
 	return store, nil
 }
 
-// AutoMigrate auto migrate
+// AutoMigrate applies the pending schema migrations, creating the
+// log table on first use. See MigrationStatus and MigrateDryRun.
 func (st *storeImplementation) AutoMigrate() error {
-	sql := st.SqlCreateTable()
+	_, err := st.migrate(false)
 
-	if st.debugEnabled {
-		log.Println(sql)
+	if err != nil {
+		log.Println(err)
+		return err
 	}
 
-	_, err := st.db.Exec(sql)
+	return nil
+}
+
+// columnExists returns whether the log table has the column
+func (st *storeImplementation) columnExists(column string) bool {
+	return st.tableColumnExists(st.logTableName, column)
+}
+
+// tableColumnExists returns whether the table exists and has the column
+func (st *storeImplementation) tableColumnExists(table string, column string) bool {
+	sqlStr, _, err := st.dialect().
+		From(table).
+		Select(goqu.C(column)).
+		Where(goqu.L("1 = 0")).
+		ToSQL()
+
+	if err != nil {
+		return false
+	}
+
+	rows, err := st.db.Query(sqlStr)
+
+	if err != nil {
+		return false
+	}
+
+	rows.Close()
+
+	return true
+}
+
+// Close stops the retention janitor, writes any queued logs and stops the
+// background writer, logging after Close returns ErrStoreClosed in asynchronous mode
+func (st *storeImplementation) Close(ctx context.Context) error {
+	var janitorErr, writerErr error
+
+	// the queued logs are written even when the janitor does not stop in time
+	if st.retentionJanitor != nil {
+		janitorErr = st.retentionJanitor.close(ctx)
+	}
+
+	if st.asyncWriter != nil {
+		writerErr = st.asyncWriter.close(ctx)
+	}
+
+	return errors.Join(janitorErr, writerErr)
+}
+
+// Flush waits until all queued logs are written
+func (st *storeImplementation) Flush(ctx context.Context) error {
+	if st.asyncWriter == nil {
+		return nil
+	}
+
+	return st.asyncWriter.flush(ctx)
+}
+
+// Enabled returns whether logs of the level are stored, callers
+// can use it to skip building expensive context data
+func (st *storeImplementation) Enabled(level string) bool {
+	severity, err := ParseLevel(level)
+
+	// logs of unknown levels are always stored
+	if err != nil {
+		return true
+	}
+
+	return severity.AtLeast(Level(st.minLevelSeverity.Load()))
+}
+
+// MinLevel returns the minimum level stored
+func (st *storeImplementation) MinLevel() string {
+	severity := Level(st.minLevelSeverity.Load())
+
+	if !severity.IsValid() {
+		return LevelTrace
+	}
+
+	return severity.String()
+}
+
+// SetMinLevel changes the minimum level stored, it is safe to call
+// while other goroutines are logging
+func (st *storeImplementation) SetMinLevel(level string) error {
+	severity, err := ParseLevel(level)
 
 	if err != nil {
-		log.Println(err)
 		return err
 	}
 
+	st.minLevelSeverity.Store(int32(severity))
+
 	return nil
 }
 
@@ -86,17 +319,83 @@ func (st *storeImplementation) EnableDebug(debug bool) {
 	st.debugEnabled = debug
 }
 
-// Log adds a log
-func (st *storeImplementation) Log(logEntry *Log) error {
+// dialect returns the goqu dialect matching the database driver
+func (st *storeImplementation) dialect() goqu.DialectWrapper {
+	switch st.dbDriverName {
+	case sb.DIALECT_SQLITE:
+		return goqu.Dialect("sqlite3")
+	case sb.DIALECT_MSSQL:
+		return goqu.Dialect("sqlserver")
+	}
+
+	return goqu.Dialect(st.dbDriverName)
+}
+
+// prepareLog sets the ID, severity, time and store defaults of the log if missing
+// and truncates the source to the length of its columns
+func (st *storeImplementation) prepareLog(logEntry *Log) {
+	if logEntry.Service == "" {
+		logEntry.Service = st.service
+	}
+	if logEntry.Host == "" {
+		logEntry.Host = st.host
+	}
+	if logEntry.Environment == "" {
+		logEntry.Environment = st.environment
+	}
+	if logEntry.Version == "" {
+		logEntry.Version = st.version
+	}
 	if logEntry.ID == "" {
 		logEntry.ID = uid.MicroUid()
 	}
+	if logEntry.Severity == 0 {
+		// unknown levels are stored with severity 0
+		logEntry.Severity, _ = ParseLevel(logEntry.Level)
+	}
+	truncateSource(logEntry)
 	if logEntry.Time == nil {
 		t := carbon.Now(carbon.UTC).StdTime()
 		logEntry.Time = &t
+	} else {
+		// Times are stored in UTC so they compare correctly in range and cursor queries
+		t := logEntry.Time.UTC()
+		logEntry.Time = &t
+	}
+}
+
+// Log adds a log
+func (st *storeImplementation) Log(logEntry *Log) error {
+	return st.LogContext(context.Background(), logEntry)
+}
+
+// LogContext adds a log, the context can cancel the write and
+// the values of the context extractors are added to the log context.
+// Logs below the minimum level are dropped.
+func (st *storeImplementation) LogContext(ctx context.Context, logEntry *Log) error {
+	if !st.Enabled(logEntry.Level) {
+		return nil
 	}
 
-	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+	if st.callerEnabled && logEntry.SourceFile == "" {
+		st.captureCaller(logEntry)
+	}
+
+	st.prepareLog(logEntry)
+	st.applyContextExtractors(ctx, logEntry)
+
+	if st.asyncWriter != nil {
+		// a copy is queued, so the caller can reuse the entry
+		queuedEntry := *logEntry
+		return st.asyncWriter.enqueue(ctx, &queuedEntry)
+	}
+
+	return st.insertLog(ctx, logEntry)
+}
+
+// insertLog writes a single log to the database
+func (st *storeImplementation) insertLog(ctx context.Context, logEntry *Log) error {
+	sqlStr, sqlParams, err := st.dialect().
 		Insert(st.logTableName).
 		Rows(logEntry).
 		Prepared(true).
@@ -110,6 +409,140 @@ func (st *storeImplementation) Log(logEntry *Log) error {
 		log.Println(sqlStr)
 	}
 
+	_, err = st.db.ExecContext(ctx, sqlStr, sqlParams...)
+
+	if err != nil {
+		if st.debugEnabled {
+			log.Println(err.Error())
+		}
+		return err
+	}
+
+	return nil
+}
+
+// LogList returns the logs matching the query
+func (st *storeImplementation) LogList(query LogQueryInterface) ([]Log, error) {
+	if query == nil {
+		query = LogQuery()
+	}
+
+	if err := st.validateLogQuery(context.Background(), query); err != nil {
+		return nil, err
+	}
+
+	sqlStr, sqlParams, err := st.logSelectQuery(query).
+		Select(logSelectColumns()...).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := st.db.Query(sqlStr, sqlParams...)
+
+	if err != nil {
+		if st.debugEnabled {
+			log.Println(err.Error())
+		}
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	logs := []Log{}
+
+	for rows.Next() {
+		logEntry, err := scanLog(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		logs = append(logs, *logEntry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return logs, nil
+}
+
+// LogCount returns the number of logs matching the query,
+// the limit and offset of the query are ignored
+func (st *storeImplementation) LogCount(query LogQueryInterface) (int64, error) {
+	if query == nil {
+		query = LogQuery()
+	}
+
+	if err := st.validateLogQuery(context.Background(), query); err != nil {
+		return 0, err
+	}
+
+	sqlStr, sqlParams, err := st.logSelectQuery(query).
+		ClearOrder().
+		ClearLimit().
+		ClearOffset().
+		Select(goqu.COUNT(goqu.Star()).As("count")).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	var count int64
+	err = st.db.QueryRow(sqlStr, sqlParams...).Scan(&count)
+
+	if err != nil {
+		if st.debugEnabled {
+			log.Println(err.Error())
+		}
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// LogDelete deletes a log
+func (st *storeImplementation) LogDelete(logEntry *Log) error {
+	if logEntry == nil {
+		return errors.New("log store: log entry is nil")
+	}
+
+	return st.LogDeleteByID(logEntry.ID)
+}
+
+// LogDeleteByID deletes a log by ID
+func (st *storeImplementation) LogDeleteByID(id string) error {
+	if id == "" {
+		return errors.New("log store: log id is empty")
+	}
+
+	sqlStr, sqlParams, err := st.dialect().
+		Delete(st.logTableName).
+		Where(goqu.C(COLUMN_ID).Eq(id)).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
 	_, err = st.db.Exec(sqlStr, sqlParams...)
 
 	if err != nil {
@@ -122,6 +555,25 @@ func (st *storeImplementation) Log(logEntry *Log) error {
 	return nil
 }
 
+// LogFindByID returns the log with the given ID, or nil if not found
+func (st *storeImplementation) LogFindByID(id string) (*Log, error) {
+	if id == "" {
+		return nil, errors.New("log store: log id is empty")
+	}
+
+	logs, err := st.LogList(LogQuery().SetID(id).SetLimit(1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(logs) < 1 {
+		return nil, nil
+	}
+
+	return &logs[0], nil
+}
+
 // Debug adds a debug log
 func (st *storeImplementation) Debug(message string) error {
 	log := Log{
@@ -132,7 +584,7 @@ func (st *storeImplementation) Debug(message string) error {
 }
 
 // DebugWithContext adds a debug log with context data
-func (st *storeImplementation) DebugWithContext(message string, context interface{}) error {
+func (st *storeImplementation) DebugWithContext(message string, context interface{}, opts ...LogOption) error {
 	contextBytes, err := json.Marshal(context)
 
 	if err != nil {
@@ -145,6 +597,9 @@ func (st *storeImplementation) DebugWithContext(message string, context interfac
 		Message: message,
 		Context: string(contextBytes),
 	}
+
+	applyLogOptions(&log, opts)
+
 	return st.Log(&log)
 }
 
@@ -158,7 +613,7 @@ func (st *storeImplementation) Error(message string) error {
 }
 
 // ErrorWithContext adds an error log with context data
-func (st *storeImplementation) ErrorWithContext(message string, context interface{}) error {
+func (st *storeImplementation) ErrorWithContext(message string, context interface{}, opts ...LogOption) error {
 	contextBytes, err := json.Marshal(context)
 
 	if err != nil {
@@ -171,10 +626,14 @@ func (st *storeImplementation) ErrorWithContext(message string, context interfac
 		Message: message,
 		Context: string(contextBytes),
 	}
+
+	applyLogOptions(&log, opts)
+
 	return st.Log(&log)
 }
 
-// Fatal adds an fatal log and calls os.Exit(1) after logging
+// Fatal adds an fatal log and calls os.Exit(1) after logging,
+// unless configured otherwise with the FatalBehavior option
 func (st *storeImplementation) Fatal(message string) error {
 	log := Log{
 		Level:   LevelFatal,
@@ -182,12 +641,12 @@ func (st *storeImplementation) Fatal(message string) error {
 	}
 
 	err := st.Log(&log)
-	// os.Exit(1)
+	st.exitAfterFatal()
 	return err
 }
 
 // FatalWithContext adds a fatal log with context data and calls os.Exit(1) after logging
-func (st *storeImplementation) FatalWithContext(message string, context interface{}) error {
+func (st *storeImplementation) FatalWithContext(message string, context interface{}, opts ...LogOption) error {
 	contextBytes, err := json.Marshal(context)
 
 	if err != nil {
@@ -201,8 +660,10 @@ func (st *storeImplementation) FatalWithContext(message string, context interfac
 		Context: string(contextBytes),
 	}
 
+	applyLogOptions(&log, opts)
+
 	err = st.Log(&log)
-	// os.Exit(1)
+	st.exitAfterFatal()
 	return err
 }
 
@@ -216,7 +677,7 @@ func (st *storeImplementation) Info(message string) error {
 }
 
 // InfoWithContext adds an info log with context data
-func (st *storeImplementation) InfoWithContext(message string, context interface{}) error {
+func (st *storeImplementation) InfoWithContext(message string, context interface{}, opts ...LogOption) error {
 	contextBytes, err := json.Marshal(context)
 
 	if err != nil {
@@ -229,22 +690,30 @@ func (st *storeImplementation) InfoWithContext(message string, context interface
 		Message: message,
 		Context: string(contextBytes),
 	}
+
+	applyLogOptions(&log, opts)
+
 	return st.Log(&log)
 }
 
-// Panic adds an panic log and calls panic(message) after logging
+// Panic adds an panic log with the stack trace, writes the queued logs
+// and calls panic(message) after logging
 func (st *storeImplementation) Panic(message string) {
 	log := Log{
 		Level:   LevelPanic,
 		Message: message,
 	}
 
+	withStack()(&log)
+
 	st.Log(&log)
+	st.flushBeforePanic()
 	panic(message)
 }
 
-// PanicWithContext adds a panic log with context data and calls panic(message) after logging
-func (st *storeImplementation) PanicWithContext(message string, context interface{}) {
+// PanicWithContext adds a panic log with context data and the stack trace
+// and calls panic(message) after logging
+func (st *storeImplementation) PanicWithContext(message string, context interface{}, opts ...LogOption) {
 	contextBytes, err := json.Marshal(context)
 
 	if err != nil {
@@ -253,12 +722,15 @@ func (st *storeImplementation) PanicWithContext(message string, context interfac
 	}
 
 	log := Log{
-		Level:   LevelFatal,
+		Level:   LevelPanic,
 		Message: message,
 		Context: string(contextBytes),
 	}
 
+	applyLogOptions(&log, append(opts[:len(opts):len(opts)], withStack()))
+
 	st.Log(&log)
+	st.flushBeforePanic()
 	panic(message)
 }
 
@@ -273,7 +745,7 @@ func (st *storeImplementation) Trace(message string) error {
 }
 
 // TraceWithContext adds a trace log with context data
-func (st *storeImplementation) TraceWithContext(message string, context interface{}) error {
+func (st *storeImplementation) TraceWithContext(message string, context interface{}, opts ...LogOption) error {
 	contextBytes, err := json.Marshal(context)
 
 	if err != nil {
@@ -287,6 +759,8 @@ func (st *storeImplementation) TraceWithContext(message string, context interfac
 		Context: string(contextBytes),
 	}
 
+	applyLogOptions(&log, opts)
+
 	return st.Log(&log)
 }
 
@@ -301,7 +775,7 @@ func (st *storeImplementation) Warn(message string) error {
 }
 
 // WarnWithContext adds a warn log with context data
-func (st *storeImplementation) WarnWithContext(message string, context interface{}) error {
+func (st *storeImplementation) WarnWithContext(message string, context interface{}, opts ...LogOption) error {
 	contextBytes, err := json.Marshal(context)
 
 	if err != nil {
@@ -315,5 +789,7 @@ func (st *storeImplementation) WarnWithContext(message string, context interface
 		Context: string(contextBytes),
 	}
 
+	applyLogOptions(&log, opts)
+
 	return st.Log(&log)
 }
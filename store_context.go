@@ -0,0 +1,180 @@
+package logstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Names the common correlation values are stored under in the log context
+const (
+	ContextKeyRequestID = "request_id"
+	ContextKeyTraceID   = "trace_id"
+	ContextKeySpanID    = "span_id"
+	ContextKeyUserID    = "user_id"
+)
+
+// ContextExtractor returns a correlation value from the context,
+// or an empty string if the context does not carry it
+type ContextExtractor func(ctx context.Context) string
+
+// ContextValueExtractor returns an extractor reading ctx.Value(key),
+// formatting non string values with fmt
+func ContextValueExtractor(key any) ContextExtractor {
+	return func(ctx context.Context) string {
+		value := ctx.Value(key)
+
+		if value == nil {
+			return ""
+		}
+
+		if s, ok := value.(string); ok {
+			return s
+		}
+
+		return fmt.Sprint(value)
+	}
+}
+
+// applyContextExtractors adds the values of the context extractors to the log context.
+// Values extracted under ContextKeyRequestID, ContextKeyTraceID, ContextKeySpanID
+// and ContextKeyUserID also fill the correlation columns the log does not set.
+func (st *storeImplementation) applyContextExtractors(ctx context.Context, logEntry *Log) {
+	if ctx == nil || len(st.contextExtractors) < 1 {
+		return
+	}
+
+	values := map[string]any{}
+
+	for name, extractor := range st.contextExtractors {
+		if value := extractor(ctx); value != "" {
+			values[name] = value
+			setCorrelationValue(logEntry, name, value)
+		}
+	}
+
+	addContextValues(logEntry, values)
+}
+
+// setCorrelationValue sets the correlation column of the log
+// the name is stored under, unless the log already sets it
+func setCorrelationValue(logEntry *Log, name string, value string) {
+	var field *string
+
+	switch name {
+	case ContextKeyRequestID:
+		field = &logEntry.RequestID
+	case ContextKeyTraceID:
+		field = &logEntry.TraceID
+	case ContextKeySpanID:
+		field = &logEntry.SpanID
+	case ContextKeyUserID:
+		field = &logEntry.UserID
+	default:
+		return
+	}
+
+	if *field == "" {
+		*field = value
+	}
+}
+
+// addContextValues adds the values to the log context. A JSON object
+// context is extended with the values, existing keys are kept, any other
+// context is moved under the "data" key.
+func addContextValues(logEntry *Log, values map[string]any) {
+	if len(values) < 1 {
+		return
+	}
+
+	if strings.TrimSpace(logEntry.Context) != "" {
+		existing := map[string]any{}
+
+		if err := json.Unmarshal([]byte(logEntry.Context), &existing); err == nil {
+			for name, value := range existing {
+				values[name] = value
+			}
+		} else {
+			var data any
+			if err := json.Unmarshal([]byte(logEntry.Context), &data); err != nil {
+				data = logEntry.Context
+			}
+			values["data"] = data
+		}
+	}
+
+	contextBytes, err := json.Marshal(values)
+
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	logEntry.Context = string(contextBytes)
+}
+
+// logCtx adds a log with the level, message and context data
+func (st *storeImplementation) logCtx(ctx context.Context, level string, message string, data interface{}, opts []LogOption) error {
+	logEntry := Log{
+		Level:   level,
+		Message: message,
+	}
+
+	if data != nil {
+		contextBytes, err := json.Marshal(data)
+
+		if err != nil {
+			log.Println(err)
+			contextBytes = []byte("JSON encode error")
+		}
+
+		logEntry.Context = string(contextBytes)
+	}
+
+	applyLogOptions(&logEntry, opts)
+
+	return st.LogContext(ctx, &logEntry)
+}
+
+// DebugCtx adds a debug log with optional context data
+func (st *storeImplementation) DebugCtx(ctx context.Context, message string, data interface{}, opts ...LogOption) error {
+	return st.logCtx(ctx, LevelDebug, message, data, opts)
+}
+
+// ErrorCtx adds an error log with optional context data
+func (st *storeImplementation) ErrorCtx(ctx context.Context, message string, data interface{}, opts ...LogOption) error {
+	return st.logCtx(ctx, LevelError, message, data, opts)
+}
+
+// FatalCtx adds a fatal log with optional context data and calls os.Exit(1) after logging,
+// unless configured otherwise with the FatalBehavior option
+func (st *storeImplementation) FatalCtx(ctx context.Context, message string, data interface{}, opts ...LogOption) error {
+	err := st.logCtx(ctx, LevelFatal, message, data, opts)
+	st.exitAfterFatal()
+	return err
+}
+
+// InfoCtx adds an info log with optional context data
+func (st *storeImplementation) InfoCtx(ctx context.Context, message string, data interface{}, opts ...LogOption) error {
+	return st.logCtx(ctx, LevelInfo, message, data, opts)
+}
+
+// PanicCtx adds a panic log with optional context data and the stack trace
+// and calls panic(message) after logging
+func (st *storeImplementation) PanicCtx(ctx context.Context, message string, data interface{}, opts ...LogOption) {
+	st.logCtx(ctx, LevelPanic, message, data, append(opts[:len(opts):len(opts)], withStack()))
+	st.flushBeforePanic()
+	panic(message)
+}
+
+// TraceCtx adds a trace log with optional context data
+func (st *storeImplementation) TraceCtx(ctx context.Context, message string, data interface{}, opts ...LogOption) error {
+	return st.logCtx(ctx, LevelTrace, message, data, opts)
+}
+
+// WarnCtx adds a warn log with optional context data
+func (st *storeImplementation) WarnCtx(ctx context.Context, message string, data interface{}, opts ...LogOption) error {
+	return st.logCtx(ctx, LevelWarning, message, data, opts)
+}
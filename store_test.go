@@ -1,11 +1,19 @@
 package logstore
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gouniverse/sb"
 	"github.com/gouniverse/uid"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -40,6 +48,57 @@ func TestStoreCreate(t *testing.T) {
 	}
 }
 
+func TestStoreSqlCreateTableDriverName(t *testing.T) {
+	db := InitDB("test_log_store_create.db")
+
+	// the driver name given in the options wins over the one detected from the connection
+	store, err := NewStore(NewStoreOptions{
+		DB:           db,
+		DbDriverName: sb.DIALECT_MYSQL,
+		LogTableName: "log_create",
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	sqlStr := store.SqlCreateTable()
+
+	if !strings.Contains(sqlStr, "`log_create`") || !strings.Contains(sqlStr, "DATETIME(6)") {
+		t.Fatalf("Expected the MySQL create table statement, received %s", sqlStr)
+	}
+}
+
+func TestStoreSqlCreateTableMatchesMigrations(t *testing.T) {
+	db := InitDB("test_log_store_create.db")
+
+	// the column types the widen message and time precision migrations end with
+	expected := map[string][]string{
+		sb.DIALECT_MYSQL: {"`message` LONGTEXT NOT NULL", "`context` LONGTEXT NOT NULL", "`time` DATETIME(6) NOT NULL"},
+		sb.DIALECT_MSSQL: {`"message" NVARCHAR(MAX) NOT NULL`, `"context" NVARCHAR(MAX) NOT NULL`},
+	}
+
+	for driverName, columns := range expected {
+		store, err := NewStore(NewStoreOptions{
+			DB:           db,
+			DbDriverName: driverName,
+			LogTableName: "log_create",
+		})
+
+		if err != nil {
+			t.Fatalf("Store could not be created: " + err.Error())
+		}
+
+		sqlStr := store.SqlCreateTable()
+
+		for _, column := range columns {
+			if !strings.Contains(sqlStr, column) {
+				t.Fatalf("Expected [%s] in the %s create table statement, received %s", column, driverName, sqlStr)
+			}
+		}
+	}
+}
+
 // func TestWithAutoMigrate(t *testing.T) {
 // 	db := InitDB("test_log_store_automigrate.db")
 
@@ -243,14 +302,20 @@ func Test_Store_ErrorWithContext(t *testing.T) {
 	}
 }
 
-// Fatal methods uses system level API to terminate program (os.Exit)
+// Fatal methods uses system level API to terminate program (os.Exit),
+// the tests substitute a recorder for the exit function
 func Test_Store_Fatal(t *testing.T) {
 	db := InitDB("test_log_store_log.db")
 
+	exitCodes := []int{}
+
 	s, err := NewStore(NewStoreOptions{
 		DB:                 db,
 		LogTableName:       "log",
 		AutomigrateEnabled: true,
+		ExitFunc: func(code int) {
+			exitCodes = append(exitCodes, code)
+		},
 	})
 
 	if err != nil {
@@ -261,15 +326,24 @@ func Test_Store_Fatal(t *testing.T) {
 	if err != nil {
 		t.Fatal("Unexpected error: ", err.Error())
 	}
+
+	if len(exitCodes) != 1 || exitCodes[0] != 1 {
+		t.Fatalf("Expected exit with code 1, received %v", exitCodes)
+	}
 }
 
 func Test_Store_FatalWithContext(t *testing.T) {
 	db := InitDB("test_log_store_log.db")
 
+	exitCodes := []int{}
+
 	s, err := NewStore(NewStoreOptions{
 		DB:                 db,
 		LogTableName:       "log",
 		AutomigrateEnabled: true,
+		ExitFunc: func(code int) {
+			exitCodes = append(exitCodes, code)
+		},
 	})
 
 	if err != nil {
@@ -280,6 +354,68 @@ func Test_Store_FatalWithContext(t *testing.T) {
 	if err != nil {
 		t.Fatal("Unexpected error: ", err.Error())
 	}
+
+	if len(exitCodes) != 1 || exitCodes[0] != 1 {
+		t.Fatalf("Expected exit with code 1, received %v", exitCodes)
+	}
+}
+
+func Test_Store_FatalFlushesBeforeExit(t *testing.T) {
+	db := InitDB("test_log_store_fatal_flush.db")
+
+	var countAtExit int64 = -1
+	var s *storeImplementation
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		AsyncEnabled:       true,
+		AsyncFlushInterval: time.Hour,
+		ExitFunc: func(code int) {
+			countAtExit, _ = s.LogCount(LogQuery())
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	s.Info("queued")
+	s.FatalCtx(context.Background(), "fatal", nil)
+
+	if countAtExit != 2 {
+		t.Fatalf("Expected 2 logs written before exit, received %d", countAtExit)
+	}
+}
+
+func Test_Store_FatalBehaviorLogOnly(t *testing.T) {
+	db := InitDB("test_log_store_fatal_log_only.db")
+
+	exited := false
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		FatalBehavior:      FatalBehaviorLogOnly,
+		ExitFunc: func(code int) {
+			exited = true
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	err = s.Fatal("fatal")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if exited {
+		t.Fatal("Expected no exit with FatalBehaviorLogOnly")
+	}
 }
 
 func Test_Store_Info(t *testing.T) {
@@ -395,3 +531,2321 @@ func Test_Store_WarnWithContext(t *testing.T) {
 		t.Fatal("Unexpected error: ", err.Error())
 	}
 }
+
+func Test_Store_LogList(t *testing.T) {
+	db := InitDB("test_log_store_log_list.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	entries := []Log{
+		{ID: "1", Level: LevelDebug, Message: "debug message"},
+		{ID: "2", Level: LevelInfo, Message: "user signed in"},
+		{ID: "3", Level: LevelError, Message: "user sign in failed"},
+		{ID: "4", Level: LevelError, Message: "disk full"},
+	}
+
+	for i := range entries {
+		entryTime := base.Add(time.Duration(i) * time.Minute)
+		entries[i].Time = &entryTime
+		if err := s.Log(&entries[i]); err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+	}
+
+	all, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(all) != 4 {
+		t.Fatalf("Expected 4 logs, received %d", len(all))
+	}
+
+	if all[0].ID != "4" {
+		t.Fatalf("Expected newest log first, received [%v]", all[0].ID)
+	}
+
+	if all[0].Time == nil || !all[0].Time.Equal(base.Add(3*time.Minute)) {
+		t.Fatalf("Expected time [%v], received [%v]", base.Add(3*time.Minute), all[0].Time)
+	}
+
+	errorLogs, err := s.LogList(LogQuery().SetLevel(LevelError).SetSortDirection("asc"))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(errorLogs) != 2 || errorLogs[0].ID != "3" || errorLogs[1].ID != "4" {
+		t.Fatalf("Expected error logs [3 4], received %v", errorLogs)
+	}
+
+	levels, err := s.LogList(LogQuery().SetLevelIn([]string{LevelDebug, LevelInfo}))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(levels) != 2 {
+		t.Fatalf("Expected 2 logs, received %d", len(levels))
+	}
+
+	ranged, err := s.LogList(LogQuery().
+		SetTimeGte(base.Add(time.Minute)).
+		SetTimeLte(base.Add(2 * time.Minute)))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(ranged) != 2 {
+		t.Fatalf("Expected 2 logs in time range, received %d", len(ranged))
+	}
+
+	messages, err := s.LogList(LogQuery().SetMessageContains("sign"))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 logs containing [sign], received %d", len(messages))
+	}
+
+	paged, err := s.LogList(LogQuery().
+		SetIDIn([]string{"1", "2", "3"}).
+		SetOrderBy(COLUMN_ID).
+		SetSortDirection("asc").
+		SetLimit(1).
+		SetOffset(1))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(paged) != 1 || paged[0].ID != "2" {
+		t.Fatalf("Expected log [2], received %v", paged)
+	}
+
+	err = s.LogBatch([]*Log{
+		{ID: "5", Level: LevelWarning, Message: "cache 100% full"},
+		{ID: "6", Level: LevelWarning, Message: "cache 1000 full"},
+		{ID: "7", Level: LevelWarning, Message: `read my_file\data.go`},
+		{ID: "8", Level: LevelWarning, Message: `read myXfile\data.go`},
+	})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	for text, expectedID := range map[string]string{"100%": "5", `my_file\`: "7"} {
+		wildcards, err := s.LogList(LogQuery().SetMessageContains(text))
+		if err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+
+		if len(wildcards) != 1 || wildcards[0].ID != expectedID {
+			t.Fatalf("Expected [%s] to match log [%s] only, received %v", text, expectedID, wildcards)
+		}
+	}
+
+	_, err = s.LogList(LogQuery().SetOrderBy("unknown"))
+	if err == nil {
+		t.Fatal("Expected error for invalid order by column")
+	}
+}
+
+func Test_Store_LogFindByID(t *testing.T) {
+	db := InitDB("test_log_store_log_find_by_id.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	logTime := time.Date(2024, 1, 1, 12, 30, 15, 0, time.UTC)
+	log := Log{
+		Level:   LevelInfo,
+		Message: "Test Message",
+		Context: `{"name":"John Doe"}`,
+		Time:    &logTime,
+	}
+
+	err = s.Log(&log)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	found, err := s.LogFindByID(log.ID)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if found == nil {
+		t.Fatal("Log not found")
+	}
+
+	if found.Level != LevelInfo || found.Message != "Test Message" || found.Context != `{"name":"John Doe"}` {
+		t.Fatalf("Unexpected log: %v", found)
+	}
+
+	if found.Time == nil || !found.Time.Equal(logTime) {
+		t.Fatalf("Expected time [%v], received [%v]", logTime, found.Time)
+	}
+
+	notFound, err := s.LogFindByID("missing")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if notFound != nil {
+		t.Fatalf("Expected nil, received %v", notFound)
+	}
+}
+
+func Test_Store_LogCountAndDelete(t *testing.T) {
+	db := InitDB("test_log_store_log_count_and_delete.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	for _, level := range []string{LevelInfo, LevelInfo, LevelError} {
+		if err := s.Log(&Log{Level: level, Message: "message"}); err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+	}
+
+	count, err := s.LogCount(LogQuery().SetLevel(LevelInfo).SetLimit(1))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 2 {
+		t.Fatalf("Expected 2 info logs, received %d", count)
+	}
+
+	errorLogs, err := s.LogList(LogQuery().SetLevel(LevelError))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(errorLogs) != 1 {
+		t.Fatalf("Expected 1 error log, received %d", len(errorLogs))
+	}
+
+	err = s.LogDelete(&errorLogs[0])
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	count, err = s.LogCount(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 2 {
+		t.Fatalf("Expected 2 logs after delete, received %d", count)
+	}
+
+	err = s.LogDeleteByID("")
+	if err == nil {
+		t.Fatal("Expected error for empty id")
+	}
+}
+
+func Test_parseLogTime(t *testing.T) {
+	expected := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+
+	values := []any{
+		expected,
+		"2024-01-02 03:04:05.123456+00:00",
+		"2024-01-02T03:04:05.123456Z",
+		[]byte("2024-01-02 03:04:05.123456"),
+	}
+
+	for _, value := range values {
+		parsed, err := parseLogTime(value)
+		if err != nil {
+			t.Fatalf("Unexpected error for [%v]: %s", value, err.Error())
+		}
+
+		if parsed == nil || !parsed.Equal(expected) {
+			t.Fatalf("Expected [%v], received [%v]", expected, parsed)
+		}
+	}
+
+	parsed, err := parseLogTime(nil)
+	if err != nil || parsed != nil {
+		t.Fatalf("Expected nil time for nil value, received [%v] [%v]", parsed, err)
+	}
+
+	_, err = parseLogTime("not a time")
+	if err == nil {
+		t.Fatal("Expected error for invalid time")
+	}
+}
+
+func Test_Store_LogListByCursor(t *testing.T) {
+	db := InitDB("test_log_store_log_list_by_cursor.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// two logs share the same time to check the id tie breaker
+	offsets := map[string]time.Duration{"a": 0, "b": time.Second, "c": time.Second, "d": 2 * time.Second, "e": 3 * time.Second}
+	for id, offset := range offsets {
+		logTime := base.Add(offset)
+		if err := s.Log(&Log{ID: id, Level: LevelInfo, Message: id, Time: &logTime}); err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+	}
+
+	ids := func(logs []Log) string {
+		result := ""
+		for _, l := range logs {
+			result += l.ID
+		}
+		return result
+	}
+
+	query := LogQuery().SetLimit(2)
+
+	page1, err := s.LogListByCursor(query, "")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if ids(page1.Logs) != "ed" || page1.PreviousCursor != "" || page1.NextCursor == "" {
+		t.Fatalf("Unexpected first page: %v", page1)
+	}
+
+	// a newer log must not shift the following pages
+	newTime := base.Add(time.Hour)
+	if err := s.Log(&Log{ID: "f", Level: LevelInfo, Message: "f", Time: &newTime}); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	page2, err := s.LogListByCursor(query, page1.NextCursor)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if ids(page2.Logs) != "cb" || page2.PreviousCursor == "" || page2.NextCursor == "" {
+		t.Fatalf("Unexpected second page: %v", page2)
+	}
+
+	page3, err := s.LogListByCursor(query, page2.NextCursor)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if ids(page3.Logs) != "a" || page3.NextCursor != "" {
+		t.Fatalf("Unexpected third page: %v", page3)
+	}
+
+	back, err := s.LogListByCursor(query, page3.PreviousCursor)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if ids(back.Logs) != "cb" {
+		t.Fatalf("Expected [cb] walking back, received [%s]", ids(back.Logs))
+	}
+
+	first, err := s.LogListByCursor(query, back.PreviousCursor)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if ids(first.Logs) != "ed" || first.PreviousCursor == "" {
+		t.Fatalf("Unexpected page walking back: %v", first)
+	}
+
+	_, err = s.LogListByCursor(query, "not-a-cursor")
+	if err == nil {
+		t.Fatal("Expected error for invalid cursor")
+	}
+}
+
+func Test_Store_Async(t *testing.T) {
+	db := InitDB("test_log_store_async.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		AsyncEnabled:       true,
+		AsyncFlushInterval: time.Hour,
+		AsyncMaxBatchSize:  3,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := s.Info("async"); err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+	}
+
+	err = s.Flush(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	count, err := s.LogCount(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 5 {
+		t.Fatalf("Expected 5 logs after flush, received %d", count)
+	}
+
+	if err := s.Info("before close"); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	err = s.Close(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	count, err = s.LogCount(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 6 {
+		t.Fatalf("Expected 6 logs after close, received %d", count)
+	}
+
+	err = s.Info("after close")
+	if err != ErrStoreClosed {
+		t.Fatalf("Expected ErrStoreClosed, received %v", err)
+	}
+}
+
+func Test_Store_AsyncBatchWithRejectedLog(t *testing.T) {
+	db := InitDB("test_log_store_async_rejected.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		AsyncEnabled:       true,
+		AsyncFlushInterval: time.Hour,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	err = s.LogBatch([]*Log{{ID: "duplicate", Level: LevelInfo, Message: "stored"}})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	for _, logEntry := range []*Log{
+		{Level: LevelInfo, Message: "before"},
+		{ID: "duplicate", Level: LevelInfo, Message: "rejected"},
+		{Level: LevelInfo, Message: "after"},
+	} {
+		if err := s.Log(logEntry); err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+	}
+
+	if err := s.Flush(context.Background()); err == nil {
+		t.Fatal("Expected the rejected log to be reported")
+	}
+
+	count, err := s.LogCount(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 3 {
+		t.Fatalf("Expected only the rejected log to be lost, received %d logs", count)
+	}
+}
+
+func Test_asyncWriter_CloseWithBlockedEnqueue(t *testing.T) {
+	release := make(chan struct{})
+
+	writer := newAsyncWriter(1, time.Hour, 1, func(entries []*Log) error {
+		<-release
+		return nil
+	})
+
+	defer close(release)
+
+	// the first entry blocks the writer, the second fills the queue
+	for _, message := range []string{"first", "second"} {
+		if err := writer.enqueue(context.Background(), &Log{Message: message}); err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	blocked := make(chan error, 1)
+
+	go func() {
+		blocked <- writer.enqueue(context.Background(), &Log{Message: "third"})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := writer.close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the close to time out, received %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected the close to return at the deadline, returned after %v", elapsed)
+	}
+
+	if err := <-blocked; !errors.Is(err, ErrStoreClosed) {
+		t.Fatalf("Expected the blocked enqueue to fail as closed, received %v", err)
+	}
+}
+
+func Test_Store_LogBatch(t *testing.T) {
+	db := InitDB("test_log_store_log_batch.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	// more rows than fit in a single SQLite statement
+	entries := []*Log{}
+	for i := 0; i < 1000; i++ {
+		entries = append(entries, &Log{Level: LevelInfo, Message: "batch"})
+	}
+
+	err = s.LogBatch(entries)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if entries[0].ID == "" || entries[0].Time == nil {
+		t.Fatal("Expected ID and time to be set")
+	}
+
+	count, err := s.LogCount(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 1000 {
+		t.Fatalf("Expected 1000 logs, received %d", count)
+	}
+
+	// a failing chunk rolls back the whole batch
+	duplicate := []*Log{
+		{ID: "duplicate", Level: LevelInfo, Message: "first"},
+		{ID: "duplicate", Level: LevelInfo, Message: "second"},
+	}
+
+	err = s.LogBatch(duplicate)
+	if err == nil {
+		t.Fatal("Expected error for duplicate IDs")
+	}
+
+	count, err = s.LogCount(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 1000 {
+		t.Fatalf("Expected 1000 logs after rollback, received %d", count)
+	}
+}
+
+type testContextKey string
+
+func Test_Store_LogContext(t *testing.T) {
+	db := InitDB("test_log_store_log_context.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		ContextExtractors: map[string]ContextExtractor{
+			ContextKeyRequestID: ContextValueExtractor(testContextKey("request_id")),
+			ContextKeyUserID:    ContextValueExtractor(testContextKey("user_id")),
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	ctx := context.WithValue(context.Background(), testContextKey("request_id"), "req-1")
+
+	err = s.InfoCtx(ctx, "with data", map[string]string{"name": "John Doe"})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	err = s.WarnCtx(ctx, "without data", nil)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	err = s.ErrorCtx(ctx, "with scalar data", "details")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	expected := map[string]string{
+		LevelInfo:    `{"name":"John Doe","request_id":"req-1"}`,
+		LevelWarning: `{"request_id":"req-1"}`,
+		LevelError:   `{"data":"details","request_id":"req-1"}`,
+	}
+
+	for level, expectedContext := range expected {
+		logs, err := s.LogList(LogQuery().SetLevel(level))
+		if err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+
+		if len(logs) != 1 || logs[0].Context != expectedContext {
+			t.Fatalf("Expected %s context [%s], received %v", level, expectedContext, logs)
+		}
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = s.DebugCtx(cancelled, "cancelled", nil)
+	if err == nil {
+		t.Fatal("Expected error for cancelled context")
+	}
+}
+
+func Test_Store_MinLevel(t *testing.T) {
+	db := InitDB("test_log_store_min_level.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		MinLevel:           LevelInfo,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	if s.Enabled(LevelDebug) || !s.Enabled(LevelInfo) || !s.Enabled(LevelError) {
+		t.Fatal("Unexpected enabled levels for minimum level info")
+	}
+
+	s.Trace("trace")
+	s.Debug("debug")
+	s.Info("info")
+	s.Error("error")
+
+	count, err := s.LogCount(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 2 {
+		t.Fatalf("Expected 2 logs at or above info, received %d", count)
+	}
+
+	err = s.SetMinLevel(LevelTrace)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if s.MinLevel() != LevelTrace {
+		t.Fatalf("Expected minimum level [%s], received [%s]", LevelTrace, s.MinLevel())
+	}
+
+	s.Trace("trace")
+
+	count, err = s.LogCount(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 3 {
+		t.Fatalf("Expected 3 logs after lowering the minimum level, received %d", count)
+	}
+
+	err = s.SetMinLevel(LevelError)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	err = s.LogBatch([]*Log{
+		{Level: LevelDebug, Message: "batch debug"},
+		{Level: LevelError, Message: "batch error"},
+	})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	count, err = s.LogCount(LogQuery().SetMessageContains("batch"))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 1 {
+		t.Fatalf("Expected only the batch error to be stored, received %d", count)
+	}
+
+	err = s.SetMinLevel("unknown")
+	if err == nil {
+		t.Fatal("Expected error for unknown level")
+	}
+}
+
+func Test_Store_SeverityQuery(t *testing.T) {
+	db := InitDB("test_log_store_severity_query.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		FatalBehavior:      FatalBehaviorLogOnly,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	s.Debug("debug")
+	s.Info("info")
+	s.Warn("warn")
+	s.Error("error")
+	s.Fatal("fatal")
+
+	logs, err := s.LogList(LogQuery().SetSeverityGte(SeverityWarning).SetOrderBy(COLUMN_SEVERITY).SetSortDirection("asc"))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 3 || logs[0].Level != LevelWarning || logs[0].Severity != SeverityWarning {
+		t.Fatalf("Expected warning and above, received %v", logs)
+	}
+
+	err = s.Log(&Log{Level: "WARN", Message: "alias"})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	logs, err = s.LogList(LogQuery().SetSeverityIn([]Level{SeverityDebug, SeverityWarning}))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 3 {
+		t.Fatalf("Expected the debug, warning and WARN logs, received %v", logs)
+	}
+}
+
+func Test_Store_AutoMigrateAddsSeverity(t *testing.T) {
+	db := InitDB("test_log_store_automigrate_severity.db")
+
+	// the table as created before the severity column existed
+	_, err := db.Exec(`CREATE TABLE "log" ("id" TEXT(40) PRIMARY KEY NOT NULL, "level" TEXT(40) NOT NULL, "message" TEXT(510) NOT NULL, "context" TEXT NOT NULL, "time" DATETIME NOT NULL)`)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	_, err = db.Exec(`INSERT INTO "log" VALUES ('legacy', 'error', 'legacy message', '', '2024-01-01 00:00:00+00:00'), ('alias', 'WARN', 'alias message', '', '2024-01-01 00:00:00+00:00')`)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	legacy, err := s.LogFindByID("legacy")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if legacy == nil || legacy.Severity != SeverityError {
+		t.Fatalf("Expected severity to be filled in from the level, received %v", legacy)
+	}
+
+	alias, err := s.LogFindByID("alias")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if alias == nil || alias.Severity != SeverityWarning {
+		t.Fatalf("Expected severity to be filled in from the level alias, received %v", alias)
+	}
+
+	err = s.Info("new")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	for _, column := range append(sourceColumns(), correlationColumns()...) {
+		if !s.columnExists(column.Name) {
+			t.Fatalf("Expected column %s to be added", column.Name)
+		}
+	}
+}
+
+func Test_Store_WithTime(t *testing.T) {
+	db := InitDB("test_log_store_with_time.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	eventTime := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+
+	err = s.InfoWithContext("event", map[string]string{"name": "John Doe"}, WithTime(eventTime))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	err = s.WarnCtx(context.Background(), "event ctx", nil, WithTime(eventTime.Add(time.Millisecond)))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	logs, err := s.LogList(LogQuery().SetSortDirection("asc"))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 2 {
+		t.Fatalf("Expected 2 logs, received %d", len(logs))
+	}
+
+	if !logs[0].Time.Equal(eventTime) || !logs[1].Time.Equal(eventTime.Add(time.Millisecond)) {
+		t.Fatalf("Expected the explicit times with sub-second precision, received [%v] [%v]", logs[0].Time, logs[1].Time)
+	}
+}
+
+func Test_Store_PanicWithContext(t *testing.T) {
+	db := InitDB("test_log_store_panic_with_context.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != "panic message" {
+				t.Fatalf("Expected panic [panic message], received [%v]", recovered)
+			}
+		}()
+
+		s.PanicWithContext("panic message", map[string]string{"name": "John Doe"})
+	}()
+
+	logs, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 || logs[0].Level != LevelPanic {
+		t.Fatalf("Expected a panic log, received %v", logs)
+	}
+
+	stored := map[string]any{}
+	if err := json.Unmarshal([]byte(logs[0].Context), &stored); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if stored["name"] != "John Doe" || !strings.Contains(stored[ContextKeyStack].(string), "Test_Store_PanicWithContext") {
+		t.Fatalf("Expected context data and stack trace, received %v", stored)
+	}
+}
+
+func Test_Store_PanicFlush(t *testing.T) {
+	db := InitDB("test_log_store_panic_flush.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		AsyncEnabled:       true,
+		AsyncFlushInterval: time.Hour,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	panics := []func(){
+		func() { s.Panic("panic") },
+		func() { s.PanicWithContext("panic with context", nil) },
+		func() { s.PanicCtx(context.Background(), "panic ctx", nil) },
+	}
+
+	for _, panicFunc := range panics {
+		func() {
+			defer func() { recover() }()
+			panicFunc()
+		}()
+	}
+
+	// the logs are written before panicking, without waiting for a flush
+	count, err := s.LogCount(LogQuery().SetLevel(LevelPanic))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 3 {
+		t.Fatalf("Expected 3 panic logs, received %d", count)
+	}
+}
+
+func Test_Store_Recover(t *testing.T) {
+	db := InitDB("test_log_store_recover.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	panicking := func() {
+		var values map[string]int
+		values["key"] = 1 // assignment to nil map panics
+	}
+
+	func() {
+		defer s.Recover(context.Background())
+		panicking()
+	}()
+
+	logs, err := s.LogList(LogQuery().SetLevel(LevelPanic))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 || !strings.Contains(logs[0].Message, "assignment to entry in nil map") {
+		t.Fatalf("Expected the recovered panic to be logged, received %v", logs)
+	}
+
+	stored := map[string]any{}
+	if err := json.Unmarshal([]byte(logs[0].Context), &stored); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if !strings.Contains(stored[ContextKeyStack].(string), "Test_Store_Recover.func1") {
+		t.Fatalf("Expected the stack trace of the panic, received %v", stored[ContextKeyStack])
+	}
+
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != "again" {
+				t.Fatalf("Expected the panic to be raised again, received [%v]", recovered)
+			}
+		}()
+
+		defer s.RecoverAndRepanic(context.Background())
+		panic("again")
+	}()
+
+	count, err := s.LogCount(LogQuery().SetLevel(LevelPanic))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 2 {
+		t.Fatalf("Expected 2 panic logs, received %d", count)
+	}
+}
+
+func Test_Store_LogError(t *testing.T) {
+	db := InitDB("test_log_store_log_error.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	_, openErr := os.Open("does_not_exist.txt")
+	wrapped := fmt.Errorf("loading config: %w", errors.Join(openErr, errors.New("fallback failed")))
+
+	err = s.LogError(wrapped, "attempt", 3, "user_id", "USER_01")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	err = s.LogError(nil)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	logs, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 {
+		t.Fatalf("Expected one log, received %d", len(logs))
+	}
+
+	if logs[0].Level != LevelError || logs[0].Message != wrapped.Error() {
+		t.Fatalf("Expected an error log with the error message, received %v", logs[0])
+	}
+
+	stored := map[string]any{}
+	if err := json.Unmarshal([]byte(logs[0].Context), &stored); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if stored["attempt"] != float64(3) || stored["user_id"] != "USER_01" {
+		t.Fatalf("Expected the fields to be stored, received %v", stored)
+	}
+
+	if stored[ContextKeyErrorType] != "*fmt.wrapError" {
+		t.Fatalf("Expected the error type, received %v", stored[ContextKeyErrorType])
+	}
+
+	if stored[ContextKeyRootCauseType] != "syscall.Errno" {
+		t.Fatalf("Expected the root cause type, received %v", stored[ContextKeyRootCauseType])
+	}
+
+	chain, ok := stored[ContextKeyErrorChain].([]any)
+	if !ok || len(chain) != 5 {
+		t.Fatalf("Expected the wrapped, joined, path, errno and fallback errors in the chain, received %v", stored[ContextKeyErrorChain])
+	}
+
+	if !strings.Contains(stored[ContextKeyStack].(string), "Test_Store_LogError") {
+		t.Fatalf("Expected the stack trace of the caller, received %v", stored[ContextKeyStack])
+	}
+
+	if strings.Contains(stored[ContextKeyStack].(string), "logError") {
+		t.Fatalf("Expected the log store frames to be skipped, received %v", stored[ContextKeyStack])
+	}
+}
+
+func Test_errorFields(t *testing.T) {
+	values := errorFields([]any{"user_id", "u-1", 42, "attempt", 3})
+
+	expected := map[string]any{"user_id": "u-1", "!BADKEY": 42, "attempt": 3}
+
+	if fmt.Sprint(values) != fmt.Sprint(expected) {
+		t.Fatalf("Expected %v, received %v", expected, values)
+	}
+
+	values = errorFields([]any{"user_id", "u-1", "dangling"})
+
+	if values["!BADKEY"] != "dangling" || values["user_id"] != "u-1" {
+		t.Fatalf("Expected the key without a value under !BADKEY, received %v", values)
+	}
+}
+
+func Test_Store_CallerCapture(t *testing.T) {
+	db := InitDB("test_log_store_caller_capture.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		CallerEnabled:      true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	err = s.Info("captured")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	logs, err := s.LogList(LogQuery().SetSourceFileContains("store_test.go"))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 {
+		t.Fatalf("Expected the log to be found by source file, received %d logs", len(logs))
+	}
+
+	if !strings.HasSuffix(logs[0].SourceFunction, "Test_Store_CallerCapture") || logs[0].SourceLine < 1 {
+		t.Fatalf("Expected the caller to be captured, received %s:%d %s", logs[0].SourceFile, logs[0].SourceLine, logs[0].SourceFunction)
+	}
+
+	wrapped, err := NewStore(NewStoreOptions{
+		DB:            db,
+		LogTableName:  "log",
+		CallerEnabled: true,
+		CallerSkip:    1,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	logWrapper := func(message string) error {
+		return wrapped.Warn(message)
+	}
+
+	err = logWrapper("through wrapper")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	warning, err := wrapped.LogList(LogQuery().SetLevel(LevelWarning))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(warning) != 1 || !strings.HasSuffix(warning[0].SourceFunction, "Test_Store_CallerCapture") {
+		t.Fatalf("Expected the caller of the wrapper to be captured, received %v", warning)
+	}
+
+	logs, err = s.LogList(LogQuery().SetSourceFile(logs[0].SourceFile))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 2 {
+		t.Fatalf("Expected both logs to be found by source file, received %d logs", len(logs))
+	}
+}
+
+func Test_Store_CallerCaptureDisabled(t *testing.T) {
+	db := InitDB("test_log_store_caller_capture_disabled.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	err = s.Info("not captured")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	logs, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 || logs[0].SourceFile != "" || logs[0].SourceLine != 0 {
+		t.Fatalf("Expected no caller to be captured, received %v", logs)
+	}
+}
+
+func Test_Store_SourceTruncated(t *testing.T) {
+	db := InitDB("test_log_store_source_truncated.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	sourceFile := "/" + strings.Repeat("vendor/", 50) + "main.go"
+	sourceFunction := "example.com/app.(*Handler[" + strings.Repeat("map[string]any,", 20) + "]).Serve"
+
+	err = s.Log(&Log{
+		ID:             "long-source",
+		Level:          LevelInfo,
+		Message:        "long source",
+		SourceFile:     sourceFile,
+		SourceFunction: sourceFunction,
+	})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	logEntry, err := s.LogFindByID("long-source")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logEntry.SourceFile) != 255 || !strings.HasSuffix(logEntry.SourceFile, "/main.go") {
+		t.Fatalf("Expected the end of the source file, received [%s]", logEntry.SourceFile)
+	}
+
+	if len(logEntry.SourceFunction) != 255 || !strings.HasSuffix(logEntry.SourceFunction, "]).Serve") {
+		t.Fatalf("Expected the end of the source function, received [%s]", logEntry.SourceFunction)
+	}
+}
+
+func Test_Store_CorrelationColumns(t *testing.T) {
+	db := InitDB("test_log_store_correlation_columns.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		Service:            "billing",
+		Host:               "web-01",
+		Environment:        "production",
+		Version:            "v1.2.3",
+		ContextExtractors: map[string]ContextExtractor{
+			ContextKeyRequestID: ContextValueExtractor(testContextKey("request_id")),
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	ctx := context.WithValue(context.Background(), testContextKey("request_id"), "REQ_01")
+
+	err = s.InfoCtx(ctx, "defaults", nil, WithTrace("TRACE_01", "SPAN_01"), WithUserID("USER_01"))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	err = s.Log(&Log{
+		Level:       LevelInfo,
+		Message:     "overrides",
+		Service:     "worker",
+		Environment: "staging",
+		RequestID:   "REQ_02",
+	})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	logs, err := s.LogList(LogQuery().SetRequestID("REQ_01"))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 {
+		t.Fatalf("Expected one log with the request ID, received %d", len(logs))
+	}
+
+	logEntry := logs[0]
+	if logEntry.Service != "billing" || logEntry.Host != "web-01" || logEntry.Environment != "production" || logEntry.Version != "v1.2.3" {
+		t.Fatalf("Expected the store defaults, received %v", logEntry)
+	}
+
+	if logEntry.TraceID != "TRACE_01" || logEntry.SpanID != "SPAN_01" || logEntry.UserID != "USER_01" {
+		t.Fatalf("Expected the correlation IDs, received %v", logEntry)
+	}
+
+	logs, err = s.LogList(LogQuery().SetService("worker").SetEnvironment("staging"))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 || logs[0].Message != "overrides" || logs[0].RequestID != "REQ_02" || logs[0].Host != "web-01" {
+		t.Fatalf("Expected the per log overrides to win over the defaults, received %v", logs)
+	}
+
+	if err := LogQuery().SetTraceID("").Validate(); err == nil {
+		t.Fatal("Expected an empty trace ID to be rejected")
+	}
+}
+
+func Test_Store_Migrations(t *testing.T) {
+	db := InitDB("test_log_store_migrations.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:           db,
+		LogTableName: "log",
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	status, err := s.MigrationStatus()
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if status.CurrentVersion != 0 || status.IsUpToDate() || len(status.Pending) != status.LatestVersion {
+		t.Fatalf("Expected all migrations to be pending, received %v", status)
+	}
+
+	// the statements are returned, not logged, unless debug is enabled
+	logOutput := &strings.Builder{}
+	log.SetOutput(logOutput)
+	statements, err := s.MigrateDryRun()
+	log.SetOutput(os.Stderr)
+
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if logOutput.Len() > 0 {
+		t.Fatalf("Expected the dry run not to log, received %s", logOutput.String())
+	}
+
+	if len(statements) < status.LatestVersion || !strings.Contains(statements[1], `CREATE TABLE IF NOT EXISTS "log"`) {
+		t.Fatalf("Expected the statements creating the log table, received %v", statements)
+	}
+
+	if s.columnExists(COLUMN_ID) {
+		t.Fatal("Expected the dry run not to create the log table")
+	}
+
+	err = s.AutoMigrate()
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	status, err = s.MigrationStatus()
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if !status.IsUpToDate() || status.CurrentVersion != status.LatestVersion || len(status.Pending) != 0 {
+		t.Fatalf("Expected all migrations to be applied, received %v", status)
+	}
+
+	for _, column := range append(sourceColumns(), correlationColumns()...) {
+		if !s.columnExists(column.Name) {
+			t.Fatalf("Expected column %s to be added", column.Name)
+		}
+	}
+
+	statements, err = s.MigrateDryRun()
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(statements) != 0 {
+		t.Fatalf("Expected no statements once migrated, received %v", statements)
+	}
+
+	err = s.AutoMigrate()
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	err = s.Info("after migration")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+}
+
+func Test_Store_MigrationsConcurrent(t *testing.T) {
+	filepath := "test_log_store_migrations_concurrent.db"
+	os.Remove(filepath)
+
+	errs := make(chan error, 4)
+
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			// every process has its own connections, waiting while another writes
+			db, err := sql.Open("sqlite3", filepath+"?parseTime=true&_busy_timeout=10000")
+
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			defer db.Close()
+
+			s, err := NewStore(NewStoreOptions{
+				DB:           db,
+				LogTableName: "log",
+			})
+
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			errs <- s.AutoMigrate()
+		}()
+	}
+
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+	}
+
+	db, err := sql.Open("sqlite3", filepath+"?parseTime=true")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM "log_migrations"`).Scan(&count); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != len(logMigrations()) {
+		t.Fatalf("Expected each migration to be recorded once, received %d records", count)
+	}
+}
+
+func Test_Store_Indexes(t *testing.T) {
+	db := InitDB("test_log_store_indexes.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		IndexesDisabled:    true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	for _, columns := range logIndexes() {
+		exists, err := s.indexExists(s.indexName(columns))
+		if err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+		if exists {
+			t.Fatalf("Expected no index on %v when indexes are disabled", columns)
+		}
+	}
+
+	s, err = NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	for _, columns := range logIndexes() {
+		exists, err := s.indexExists(s.indexName(columns))
+		if err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+		if !exists {
+			t.Fatalf("Expected an index on %v once indexes are enabled", columns)
+		}
+	}
+
+	statements, err := s.MigrateDryRun()
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(statements) != 0 {
+		t.Fatalf("Expected existing indexes not to be created again, received %v", statements)
+	}
+}
+
+func Test_Store_RetentionChunkSize(t *testing.T) {
+	db := InitDB("test_log_store_retention_chunk_size.db")
+
+	chunkSizes := map[int]int{0: maxParamsSqlite - 1, 5000: maxParamsSqlite - 1, 500: 500}
+
+	for configured, expected := range chunkSizes {
+		s, err := NewStore(NewStoreOptions{
+			DB:                 db,
+			LogTableName:       "log",
+			RetentionChunkSize: configured,
+		})
+
+		if err != nil {
+			t.Fatalf("Store could not be created: " + err.Error())
+		}
+
+		if s.retentionChunkSize != expected {
+			t.Fatalf("Expected chunk size %d for %d on SQLite, received %d", expected, configured, s.retentionChunkSize)
+		}
+	}
+}
+
+func Test_Store_PurgeExpired(t *testing.T) {
+	db := InitDB("test_log_store_purge_expired.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		RetentionChunkSize: 2,
+		Retention: RetentionPolicy{
+			MaxAge: 7 * 24 * time.Hour,
+			LevelMaxAge: map[Level]time.Duration{
+				SeverityDebug:   3 * 24 * time.Hour,
+				SeverityError:   90 * 24 * time.Hour,
+				SeverityWarning: 90 * 24 * time.Hour,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	invalidLevels := []map[Level]time.Duration{
+		{Level(0): time.Hour},
+		{SeverityWarning: 0},
+	}
+
+	for _, levelMaxAge := range invalidLevels {
+		if err := (RetentionPolicy{LevelMaxAge: levelMaxAge}).Validate(); err == nil {
+			t.Fatalf("Expected the retention levels %v to be rejected", levelMaxAge)
+		}
+	}
+
+	daysAgo := func(days int) LogOption {
+		return WithTime(time.Now().Add(-time.Duration(days) * 24 * time.Hour))
+	}
+
+	entries := []struct {
+		level   string
+		message string
+		days    int
+	}{
+		{LevelDebug, "debug expired", 5},
+		{LevelDebug, "debug kept", 1},
+		{LevelError, "error kept", 30},
+		{LevelError, "error expired", 100},
+		{LevelInfo, "info expired 1", 10},
+		{LevelInfo, "info expired 2", 11},
+		{LevelInfo, "info expired 3", 12},
+		{LevelInfo, "info kept", 1},
+		// aliases and level names are matched by their severity
+		{"WARN", "warn kept", 30},
+		{"alert", "unknown expired", 10},
+	}
+
+	for _, entry := range entries {
+		err := s.logCtx(context.Background(), entry.level, entry.message, nil, []LogOption{daysAgo(entry.days)})
+		if err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+	}
+
+	deleted, err := s.PurgeExpired(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if deleted != 6 {
+		t.Fatalf("Expected 6 expired logs to be deleted, received %d", deleted)
+	}
+
+	logs, err := s.LogList(LogQuery().SetOrderBy(COLUMN_MESSAGE).SetSortDirection(sb.ASC))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	messages := []string{}
+	for _, logEntry := range logs {
+		messages = append(messages, logEntry.Message)
+	}
+
+	if strings.Join(messages, ",") != "debug kept,error kept,info kept,warn kept" {
+		t.Fatalf("Expected the logs within their retention to be kept, received %v", messages)
+	}
+}
+
+func Test_Store_PurgeExpiredMaxRows(t *testing.T) {
+	db := InitDB("test_log_store_purge_max_rows.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		RetentionChunkSize: 2,
+		Retention: RetentionPolicy{
+			MaxRows: 3,
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	eventTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 8; i++ {
+		err := s.InfoWithContext("log", map[string]int{"i": i}, WithTime(eventTime.Add(time.Duration(i)*time.Minute)))
+		if err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+	}
+
+	deleted, err := s.PurgeExpired(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if deleted != 5 {
+		t.Fatalf("Expected 5 logs to be deleted, received %d", deleted)
+	}
+
+	logs, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 3 || !logs[2].Time.Equal(eventTime.Add(5*time.Minute)) {
+		t.Fatalf("Expected the 3 most recent logs to be kept, received %v", logs)
+	}
+
+	deleted, err = s.PurgeExpired(context.Background())
+	if err != nil || deleted != 0 {
+		t.Fatalf("Expected nothing to be deleted within max rows, received %d %v", deleted, err)
+	}
+
+	if err := s.Info("over max rows"); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	deleted, err = s.PurgeExpired(cancelled)
+	if !errors.Is(err, context.Canceled) || deleted != 0 {
+		t.Fatalf("Expected a cancelled purge to delete nothing, received %d %v", deleted, err)
+	}
+}
+
+func Test_Store_RetentionJanitor(t *testing.T) {
+	db := InitDB("test_log_store_retention_janitor.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		Retention: RetentionPolicy{
+			MaxAge: time.Hour,
+		},
+		RetentionInterval: 10 * time.Millisecond,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	err = s.InfoWithContext("expired", nil, WithTime(time.Now().Add(-2*time.Hour)))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		count, err := s.LogCount(LogQuery())
+		if err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+		if count == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the janitor to purge the expired log")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	_, err = NewStore(NewStoreOptions{
+		DB:           db,
+		LogTableName: "log",
+		Retention:    RetentionPolicy{MaxRows: -1},
+	})
+
+	if err == nil {
+		t.Fatal("Expected a negative max rows to be rejected")
+	}
+}
+
+func Test_Store_CloseWithStuckJanitor(t *testing.T) {
+	db := InitDB("test_log_store_close_stuck_janitor.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		AsyncEnabled:       true,
+		AsyncFlushInterval: time.Hour,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	// a janitor whose purge never returns
+	s.retentionJanitor = &retentionJanitor{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	if err := s.Info("queued"); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := s.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the close to time out, received %v", err)
+	}
+
+	select {
+	case <-s.asyncWriter.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the writer to be closed despite the janitor")
+	}
+
+	count, err := s.LogCount(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 1 {
+		t.Fatalf("Expected the queued log to be written, received %d logs", count)
+	}
+}
+
+func Test_Store_Archive(t *testing.T) {
+	db := InitDB("test_log_store_archive.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		RetentionChunkSize: 2,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	day := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		err := s.InfoWithContext("archived", map[string]int{"i": i}, WithTime(day.Add(time.Duration(i)*12*time.Hour)))
+		if err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+	}
+
+	err = s.InfoWithContext("recent", nil, WithTime(day.AddDate(0, 1, 0)))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	directory := t.TempDir()
+
+	writer, err := NewArchiveWriter(ArchiveWriterOptions{
+		Directory: directory,
+	})
+
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	result, err := s.Archive(context.Background(), LogQuery().SetTimeLte(day.AddDate(0, 0, 7)), writer)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if result.Archived != 5 || result.Deleted != 5 {
+		t.Fatalf("Expected 5 logs to be archived and deleted, received %v", result)
+	}
+
+	// 12:00 on the 1st, 00:00 and 12:00 on the 2nd, ...
+	if len(result.Files) != 3 || !strings.HasSuffix(result.Files[0], "logs-2024-01-01-001.jsonl.gz") {
+		t.Fatalf("Expected one file per day, received %v", result.Files)
+	}
+
+	count, err := countArchiveLines(result.Files[1])
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 2 {
+		t.Fatalf("Expected 2 logs of the second day, received %d", count)
+	}
+
+	logs, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 || logs[0].Message != "recent" {
+		t.Fatalf("Expected only the recent log to be kept, received %v", logs)
+	}
+
+	_, err = s.Archive(context.Background(), LogQuery().SetLimit(10), writer)
+	if err == nil {
+		t.Fatal("Expected a query with a limit to be rejected")
+	}
+
+	// a reused writer reports only the logs and files of the second job
+	result, err = s.Archive(context.Background(), LogQuery(), writer)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if result.Archived != 1 || result.Deleted != 1 || len(result.Files) != 1 || !strings.HasSuffix(result.Files[0], "logs-2024-02-01-001.jsonl.gz") {
+		t.Fatalf("Expected the recent log to be archived on its own, received %v", result)
+	}
+}
+
+// archiveCancelContext is cancelled once the archive writer opens a file of the day
+type archiveCancelContext struct {
+	context.Context
+	writer *ArchiveWriter
+	day    string
+}
+
+func (ctx archiveCancelContext) Err() error {
+	for _, name := range ctx.writer.Files() {
+		if strings.Contains(name, ctx.day) {
+			return context.Canceled
+		}
+	}
+	return ctx.Context.Err()
+}
+
+func Test_Store_ArchiveInterrupted(t *testing.T) {
+	db := InitDB("test_log_store_archive_interrupted.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+		RetentionChunkSize: 2,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	day := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		err := s.InfoWithContext("archived "+strconv.Itoa(i), nil, WithTime(day.Add(time.Duration(i)*12*time.Hour)))
+		if err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+	}
+
+	writer, err := NewArchiveWriter(ArchiveWriterOptions{
+		Directory: t.TempDir(),
+	})
+
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	// the archive is interrupted after the file of the 3rd is opened
+	ctx := archiveCancelContext{Context: context.Background(), writer: writer, day: "2024-01-03"}
+
+	result, err := s.Archive(ctx, LogQuery(), writer)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected the archive to be cancelled, received: ", err)
+	}
+
+	// the logs of the completed files are deleted as each file is written
+	if result.Deleted < 3 {
+		t.Fatalf("Expected the logs of the 1st and 2nd to be deleted, received %v", result)
+	}
+
+	logs, err := s.LogList(LogQuery().SetSortDirection(sb.ASC))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) < 1 || logs[0].Message == "archived 2" || logs[len(logs)-1].Message != "archived 4" {
+		t.Fatalf("Expected the unarchived logs to be kept, received %v", logs)
+	}
+}
+
+func Test_Store_ImportArchive(t *testing.T) {
+	db := InitDB("test_log_store_import_archive.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	day := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		err := s.ErrorWithContext("archived", map[string]int{"i": i}, WithTime(day.Add(time.Duration(i)*time.Minute)))
+		if err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+	}
+
+	original, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	writer, err := NewArchiveWriter(ArchiveWriterOptions{Directory: t.TempDir()})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	result, err := s.Archive(context.Background(), LogQuery(), writer)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	// one log is back in the store, so it is skipped as duplicate
+	err = s.Log(&original[0])
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	file, err := os.Open(result.Files[0])
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+	defer file.Close()
+
+	progressCalls := 0
+
+	progress, err := s.Import(context.Background(), file, ImportOptions{
+		BatchSize: 2,
+		Progress: func(progress ImportProgress) {
+			progressCalls++
+		},
+	})
+
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if progress.Lines != 3 || progress.Imported != 2 || progress.Skipped != 1 || progressCalls != 2 {
+		t.Fatalf("Expected 2 imported and 1 skipped log in 2 batches, received %v after %d calls", progress, progressCalls)
+	}
+
+	imported, err := s.LogList(LogQuery())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(imported) != 3 {
+		t.Fatalf("Expected 3 logs, received %d", len(imported))
+	}
+
+	for i := range original {
+		if imported[i].ID != original[i].ID || imported[i].Context != original[i].Context || !imported[i].Time.Equal(*original[i].Time) || imported[i].Severity != SeverityError {
+			t.Fatalf("Expected the log to be restored, received %v instead of %v", imported[i], original[i])
+		}
+	}
+}
+
+func Test_Store_ImportLargeBatch(t *testing.T) {
+	db := InitDB("test_log_store_import_large_batch.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	err = s.Log(&Log{ID: "log-1200", Level: LevelInfo, Message: "existing"})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	// more IDs than the bound parameter limit are looked up in one batch
+	lines := strings.Builder{}
+	for i := 0; i < maxParamsSqlite+500; i++ {
+		lines.WriteString(`{"id":"log-` + strconv.Itoa(i) + `","level":"info","message":"imported"}` + "\n")
+	}
+
+	progress, err := s.Import(context.Background(), strings.NewReader(lines.String()), ImportOptions{
+		BatchSize: maxParamsSqlite + 500,
+	})
+
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if progress.Imported != int64(maxParamsSqlite+499) || progress.Skipped != 1 {
+		t.Fatalf("Expected all but the existing log to be imported, received %v", progress)
+	}
+}
+
+func Test_Store_ImportSlogJSON(t *testing.T) {
+	db := InitDB("test_log_store_import_slog.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	input := strings.Join([]string{
+		`{"time":"2024-01-02T03:04:05.123456Z","level":"WARN","msg":"disk almost full","disk":"/dev/sda1","usage":0.93}`,
+		``,
+		`{"time":"2024-01-02T03:04:06Z","level":"ERROR+4","msg":"disk full","request":{"id":"req-1"}}`,
+		`{"time":"2024-01-02T03:04:07Z","level":"DEBUG","msg":"checked"}`,
+	}, "\n")
+
+	progress, err := s.Import(context.Background(), strings.NewReader(input), ImportOptions{})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if progress.Imported != 3 || progress.Skipped != 0 {
+		t.Fatalf("Expected 3 imported logs, received %v", progress)
+	}
+
+	logs, err := s.LogList(LogQuery().SetSortDirection(sb.ASC))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 3 {
+		t.Fatalf("Expected 3 logs, received %d", len(logs))
+	}
+
+	if logs[0].Level != LevelWarning || logs[0].Message != "disk almost full" || !logs[0].Time.Equal(time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)) {
+		t.Fatalf("Expected the level, message and time of the record, received %v", logs[0])
+	}
+
+	stored := map[string]any{}
+	if err := json.Unmarshal([]byte(logs[0].Context), &stored); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if stored["disk"] != "/dev/sda1" || stored["usage"] != 0.93 || stored[SlogLevelKey] != float64(4) {
+		t.Fatalf("Expected the remaining keys in the context, received %v", stored)
+	}
+
+	if _, ok := stored["msg"]; ok {
+		t.Fatalf("Expected msg not to be repeated in the context, received %v", stored)
+	}
+
+	if logs[1].Level != LevelFatal || logs[2].Level != LevelDebug {
+		t.Fatalf("Expected the slog levels to be mapped by range, received %s and %s", logs[1].Level, logs[2].Level)
+	}
+
+	_, err = s.Import(context.Background(), strings.NewReader("{\"msg\":\"ok\"}\nnot json\n"), ImportOptions{})
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("Expected an error naming the invalid line, received %v", err)
+	}
+}
+
+func Test_Store_Export(t *testing.T) {
+	db := InitDB("test_log_store_export.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	logTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	err = s.ErrorWithContext("disk full", map[string]any{
+		"disk":    "/dev/sda1",
+		"request": map[string]any{"id": "req-1"},
+	}, WithTime(logTime))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	err = s.ErrorWithContext("timeout, retrying", nil, WithTime(logTime.Add(time.Second)))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	err = s.Info("not exported")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	query := LogQuery().SetLevel(LevelError).SetSortDirection(sb.ASC)
+
+	csvOutput := &strings.Builder{}
+
+	count, err := s.Export(context.Background(), query, csvOutput, ExportOptions{
+		Format:         ExportFormatCSV,
+		Columns:        []string{COLUMN_TIME, COLUMN_LEVEL, COLUMN_MESSAGE},
+		ContextColumns: []string{"disk", "request.id"},
+	})
+
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	expected := "time,level,message,disk,request.id\n" +
+		"2024-01-02T03:04:05.000000Z,error,disk full,/dev/sda1,req-1\n" +
+		"2024-01-02T03:04:06.000000Z,error,\"timeout, retrying\",,\n"
+
+	if count != 2 || csvOutput.String() != expected {
+		t.Fatalf("Expected CSV:\n%s\nreceived %d logs:\n%s", expected, count, csvOutput.String())
+	}
+
+	jsonlOutput := &strings.Builder{}
+
+	_, err = s.Export(context.Background(), query, jsonlOutput, ExportOptions{Format: ExportFormatNDJSON})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSpace(jsonlOutput.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 JSON lines, received %s", jsonlOutput.String())
+	}
+
+	exported := Log{}
+	if err := json.Unmarshal([]byte(lines[0]), &exported); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if exported.Message != "disk full" || !exported.Time.Equal(logTime) {
+		t.Fatalf("Expected the log as JSON, received %v", exported)
+	}
+
+	textOutput := &strings.Builder{}
+
+	_, err = s.Export(context.Background(), query.SetLimit(1), textOutput, ExportOptions{Format: ExportFormatText})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	expected = "2024-01-02T03:04:05.000000Z ERROR disk full disk=/dev/sda1 request.id=req-1\n"
+
+	if textOutput.String() != expected {
+		t.Fatalf("Expected text:\n%s\nreceived:\n%s", expected, textOutput.String())
+	}
+
+	_, err = s.Export(context.Background(), query, textOutput, ExportOptions{Format: "xml"})
+	if err == nil {
+		t.Fatal("Expected an unsupported format to be rejected")
+	}
+}
+
+func Test_Store_ContextPathQuery(t *testing.T) {
+	db := InitDB("test_log_store_context_path_query.db")
+
+	s, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		LogTableName:       "log",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Store could not be created: " + err.Error())
+	}
+
+	err = s.InfoWithContext("first", map[string]any{"request": map[string]any{"id": "req-1"}, "attempt": 3})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	err = s.InfoWithContext("second", map[string]any{"request": map[string]any{"id": "req-2"}})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	err = s.Log(&Log{Level: LevelInfo, Message: "not json", Context: "plain text"})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	logs, err := s.LogList(LogQuery().SetContextPathEquals("request.id", "req-1"))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if len(logs) != 1 || logs[0].Message != "first" {
+		t.Fatalf("Expected the log with the request ID, received %v", logs)
+	}
+
+	count, err := s.LogCount(LogQuery().SetContextPathEquals("attempt", "3"))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	if count != 1 {
+		t.Fatalf("Expected numbers to match their text, received %d logs", count)
+	}
+
+	for _, path := range []string{"request.id') OR 1=1 --", "request-id", "request.1st", "request..id"} {
+		_, err = s.LogList(LogQuery().SetContextPathEquals(path, "x"))
+		if err == nil {
+			t.Fatalf("Expected the invalid context path %s to be rejected", path)
+		}
+	}
+}
+
+func Test_Store_ContextPathQueryPostgresVersion(t *testing.T) {
+	st := &storeImplementation{dbDriverName: sb.DIALECT_POSTGRES, logTableName: "log"}
+
+	// the version is queried once, PostgreSQL 15.4
+	st.postgresVersion.Store(150004)
+
+	_, err := st.LogList(LogQuery().SetContextPathEquals("request.id", "req-1"))
+	if err == nil || !strings.Contains(err.Error(), "PostgreSQL 16") {
+		t.Fatal("Expected context path filters to be rejected before PostgreSQL 16, received: ", err)
+	}
+
+	st.postgresVersion.Store(160002)
+
+	if err := st.validateLogQuery(context.Background(), LogQuery().SetContextPathEquals("request.id", "req-1")); err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+}
+
+func Test_ExportLogs(t *testing.T) {
+	logTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	logs := []Log{
+		{ID: "1", Level: LevelInfo, Message: "started", Context: "{}", Time: &logTime},
+		{ID: "2", Level: LevelWarning, Message: "slow", Context: `{"ms":250}`, Time: &logTime},
+	}
+
+	output := &strings.Builder{}
+
+	err := ExportLogs(output, logs, ExportOptions{Format: ExportFormatText})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	expected := "2024-01-02T03:04:05.000000Z INFO started\n" +
+		"2024-01-02T03:04:05.000000Z WARNING slow ms=250\n"
+
+	if output.String() != expected {
+		t.Fatalf("Expected text:\n%s\nreceived:\n%s", expected, output.String())
+	}
+
+	if err := ExportLogs(output, logs, ExportOptions{Format: "xml"}); err == nil {
+		t.Fatal("Expected an unsupported format to be rejected")
+	}
+}
+
+func Test_ExportLogs_CSVFormulas(t *testing.T) {
+	logs := []Log{
+		{ID: "1", Level: LevelError, Message: "=HYPERLINK(\"http://example.com\")", Context: `{"delta":-5,"user":"@admin"}`},
+	}
+
+	output := &strings.Builder{}
+
+	err := ExportLogs(output, logs, ExportOptions{
+		Format:         ExportFormatCSV,
+		Columns:        []string{COLUMN_MESSAGE},
+		ContextColumns: []string{"delta", "user"},
+	})
+
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	expected := "message,delta,user\n\"'=HYPERLINK(\"\"http://example.com\"\")\",'-5,'@admin\n"
+
+	if output.String() != expected {
+		t.Fatalf("Expected the formulas to be escaped:\n%s\nreceived:\n%s", expected, output.String())
+	}
+
+	output.Reset()
+
+	err = ExportLogs(output, logs, ExportOptions{
+		Format:             ExportFormatCSV,
+		Columns:            []string{COLUMN_MESSAGE},
+		CSVFormulasAllowed: true,
+	})
+
+	if err != nil {
+		t.Fatal("Unexpected error: ", err.Error())
+	}
+
+	expected = "message\n\"=HYPERLINK(\"\"http://example.com\"\")\"\n"
+
+	if output.String() != expected {
+		t.Fatalf("Expected the formulas to be kept:\n%s\nreceived:\n%s", expected, output.String())
+	}
+}